@@ -154,3 +154,30 @@ func TestUndo(t *testing.T) {
 		t.Error("Won() should return true at end")
 	}
 }
+
+func TestHistory(t *testing.T) {
+	g, err := mock.BoardMaker3{}.GenBoard()
+	if err != nil {
+		t.Fatalf("unable to create board: %s", err)
+	}
+	moves := []sokoban.Direction{
+		sokoban.Up, sokoban.Left, sokoban.Left, sokoban.Down, sokoban.Down,
+	}
+	for _, d := range moves {
+		g.MakeMove(d)
+	}
+	g.MakeMove(sokoban.Down) // blocked by wall
+	g.MakeMove(sokoban.Right)
+	g.UndoMove()
+
+	h := g.History()
+	if len(h) != len(moves) {
+		t.Fatalf("history has %d moves, expected %d", len(h), len(moves))
+	}
+	for i := range moves {
+		if h[i] != moves[i] {
+			t.Errorf("history move %d is %s, expected %s", i,
+				sokoban.DirectionToStr(h[i]), sokoban.DirectionToStr(moves[i]))
+		}
+	}
+}
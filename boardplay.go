@@ -82,6 +82,54 @@ func (b *Board) GetScore() int {
 	return b.score
 }
 
+// MoveCount returns the number of moves in the board's history, i.e. the
+// length of the player's current solution
+func (b *Board) MoveCount() int {
+	return len(b.history)
+}
+
+// PushCount returns the number of moves in the board's history that pushed
+// a box
+func (b *Board) PushCount() int {
+	n := 0
+	for _, m := range b.history {
+		if m.boxFrom != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// History returns the directions of the moves made from the starting position
+// to reach the current position
+func (b *Board) History() []Direction {
+	dirs := make([]Direction, len(b.history))
+	for i, m := range b.history {
+		dirs[i] = moveDirection(m.from, m.to)
+	}
+	return dirs
+}
+
+// BoardStats is a summary of the progress made on a Board
+type BoardStats struct {
+	Moves   int  // moves in the current history
+	Pushes  int  // box pushes in the current history
+	Score   int  // boxes on targets
+	Targets int  // total number of targets
+	Won     bool // whether the board is solved
+}
+
+// Stats returns a summary of the board's current progress
+func (b *Board) Stats() BoardStats {
+	return BoardStats{
+		Moves:   b.MoveCount(),
+		Pushes:  b.PushCount(),
+		Score:   b.score,
+		Targets: len(b.targets),
+		Won:     b.Won(),
+	}
+}
+
 // Won returns whether the player has won the game
 func (b *Board) Won() bool {
 	return b.score > 0 && b.score == len(b.targets)
@@ -131,5 +179,19 @@ func directionDelta(d Direction) (int, int) {
 	}
 }
 
+// returns Direction enum of a single step between adjacent Points
+func moveDirection(from, to Point) Direction {
+	switch {
+	case to.Y < from.Y:
+		return Up
+	case to.Y > from.Y:
+		return Down
+	case to.X < from.X:
+		return Left
+	default:
+		return Right
+	}
+}
+
 // Flag for invalid Direction
 const invalidDir = 55
@@ -1,27 +1,49 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"time"
 
 	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/leaderboard"
 	"github.com/he-lium/sokoban/parse"
+	"github.com/he-lium/sokoban/replay"
 	"github.com/he-lium/sokoban/terminal"
 )
 
 // Play a single player sokoban game where the terminal displays the board and
-// the user enters actions through keyboard characters
+// the user enters actions through keyboard characters, optionally racing a
+// ghost replaying a recorded game or a solution in LURD notation
+
+var timeLimit = flag.Duration("time", 0, "time limit for the game, e.g. 5m")
+var maxMoves = flag.Int("moves", 0, "maximum number of moves per player")
+var maxPushes = flag.Int("pushes", 0, "maximum number of box pushes per player")
+var name = flag.String("name", os.Getenv("USER"), "nickname to record solutions under")
+var records = flag.String("records", "", "file to record solutions in for leaderboards and personal bests")
+var replays = flag.String("replays", "", "directory to record a replay of the game in")
+var ghostFile = flag.String("ghost", "", "replay or LURD solution file to race against")
+var ghostPlayer = flag.Int("ghost-player", -1, "player of the replay to race, by default the winner")
+var ghostInterval = flag.Duration("ghost-interval", 300*time.Millisecond, "time between the moves of a solution")
 
 // Board is loaded from json file given in argument
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s <board.json>\n", os.Args[0])
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <board.json>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
 		os.Exit(1)
 	}
-	json, err := ioutil.ReadFile(os.Args[1])
+	json, err := ioutil.ReadFile(flag.Arg(0))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading %s: %s\n", os.Args[1], err.Error())
+		fmt.Fprintf(os.Stderr, "Error reading %s: %s\n", flag.Arg(0), err.Error())
 		os.Exit(2)
 	}
 	gen := &parse.JSONBoard{JSONContent: json}
@@ -30,10 +52,68 @@ func main() {
 		W:        os.Stdout,
 		NPlayers: 1,
 	}
-	game, err := sokoban.InitGame(1, gen, controller)
+	if *ghostFile != "" {
+		controller.Ghost, err = loadGhost(*ghostFile, gen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading ghost %s: %s\n", *ghostFile, err.Error())
+			os.Exit(2)
+		}
+		controller.NPlayers = 2
+	}
+	game, err := sokoban.InitGame(controller.NPlayers, gen, controller)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error while starting game: %s\n", err.Error())
 		os.Exit(3)
 	}
+	if *records != "" {
+		store, err := leaderboard.OpenFileStore(*records)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %s\n", *records, err.Error())
+			os.Exit(2)
+		}
+		game.AddObserver(leaderboard.NewRecorder(store, []string{*name}, controller))
+	}
+	if *replays != "" {
+		store, err := replay.NewDirStore(*replays)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening %s: %s\n", *replays, err.Error())
+			os.Exit(2)
+		}
+		game.AddObserver(replay.NewRecorder(store))
+	}
+	game.SetLimits(sokoban.Limits{
+		TimeLimit: *timeLimit,
+		MaxMoves:  *maxMoves,
+		MaxPushes: *maxPushes,
+	})
 	game.Play()
 }
+
+// loadGhost reads a ghost from a replay of a game on the level of gen, or from
+// a solution
+func loadGhost(file string, gen sokoban.BoardMaker) (*replay.Ghost, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		return replay.GhostFromSolution(string(content), *ghostInterval)
+	}
+
+	r, err := replay.Load(content)
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := r.StartBoard()
+	if err != nil {
+		return nil, err
+	}
+	level, err := gen.GenBoard()
+	if err != nil {
+		return nil, err
+	}
+	if leaderboard.LevelHash(recorded) != leaderboard.LevelHash(level) {
+		return nil, errors.New("replay was recorded on a different level")
+	}
+	return replay.GhostFromReplay(r, *ghostPlayer)
+}
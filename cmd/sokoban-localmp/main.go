@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -15,15 +16,25 @@ import (
 
 const numPlayers = 2
 
+var timeLimit = flag.Duration("time", 0, "time limit for the game, e.g. 5m")
+var maxMoves = flag.Int("moves", 0, "maximum number of moves per player")
+var maxPushes = flag.Int("pushes", 0, "maximum number of box pushes per player")
+var perTurn = flag.Int("turn", 1, "number of actions each player makes per turn")
+
 // Board is loaded from json file given in argument
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s <board.json>\n", os.Args[0])
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <board.json>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
 		os.Exit(1)
 	}
-	json, err := ioutil.ReadFile(os.Args[1])
+	json, err := ioutil.ReadFile(flag.Arg(0))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading %s: %s\n", os.Args[1], err.Error())
+		fmt.Fprintf(os.Stderr, "Error reading %s: %s\n", flag.Arg(0), err.Error())
 		os.Exit(2)
 	}
 	gen := &parse.JSONBoard{JSONContent: json}
@@ -37,5 +48,11 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Error while starting game: %s\n", err.Error())
 		os.Exit(3)
 	}
+	game.SetLimits(sokoban.Limits{
+		TimeLimit: *timeLimit,
+		MaxMoves:  *maxMoves,
+		MaxPushes: *maxPushes,
+	})
+	game.SetSchedule(sokoban.Schedule{Mode: sokoban.MultiMove, MovesPerTurn: *perTurn})
 	game.Play()
 }
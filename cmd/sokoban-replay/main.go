@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/he-lium/sokoban/replay"
+	"github.com/he-lium/sokoban/terminal"
+)
+
+// Plays back a recorded game in the terminal. The replay is read from a file,
+// or downloaded from a server's /replays endpoint if given as a URL
+
+var speed = flag.Float64("speed", 1, "playback speed, e.g. 2 for twice as fast")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <replay.json | url>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	content, err := read(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %s\n", flag.Arg(0), err.Error())
+		os.Exit(2)
+	}
+	r, err := replay.Load(content)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading %s: %s\n", flag.Arg(0), err.Error())
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	v := &terminal.Viewer{R: os.Stdin, W: os.Stdout, Speed: *speed}
+	if err := v.Play(ctx, r); err != nil && err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "Error playing replay: %s\n", err.Error())
+		os.Exit(3)
+	}
+}
+
+// read returns the content of the file or URL
+func read(name string) ([]byte, error) {
+	if !strings.HasPrefix(name, "http://") && !strings.HasPrefix(name, "https://") {
+		return ioutil.ReadFile(name)
+	}
+	resp, err := http.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server responded %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
@@ -1,13 +1,217 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
 
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/leaderboard"
 	"github.com/he-lium/sokoban/mock"
+	"github.com/he-lium/sokoban/parse"
+	"github.com/he-lium/sokoban/replay"
 	"github.com/he-lium/sokoban/websocket"
 )
 
+// Runs the sokoban websocket server. Settings are taken from command line
+// flags, falling back to the JSON config file given by -config, e.g.
+//	{"addr": ":9000", "room-size": 4, "pong-wait": "30s"}
+
+var cfg = websocket.DefaultConfig()
+
+var configFile = flag.String("config", "", "JSON file of flag values; flags given on the command line take precedence")
+var levelFile = flag.String("level", "", "JSON level file to play")
+var levelDir = flag.String("levels", "", "directory of JSON level files to play in turn")
+var generator = flag.String("gen", "mock3", "built-in level generator to play if no level files are given: mock1, mock2 or mock3")
+var queues queueFlags
+var records = flag.String("records", "", "file to record solutions in for leaderboards and personal bests")
+var replays = flag.String("replays", "", "directory to record replays of games in")
+
+func init() {
+	flag.StringVar(&cfg.Addr, "addr", cfg.Addr, "address to listen on")
+	flag.DurationVar(&cfg.PongWait, "pong-wait", cfg.PongWait, "time allowed to receive a pong from a client")
+	flag.DurationVar(&cfg.WriteWait, "write-wait", cfg.WriteWait, "time allowed to write a message to a client")
+	flag.DurationVar(&cfg.ReconnectGrace, "reconnect-grace", cfg.ReconnectGrace, "time a disconnected player's slot is held for them to reconnect")
+	flag.DurationVar(&cfg.StateInterval, "state-interval", cfg.StateInterval, "time between full game state messages, 0 to only send on request")
+	flag.IntVar(&cfg.RoomSize, "room-size", cfg.RoomSize, fmt.Sprintf("most players in a game, up to %d", websocket.MaxRoomSize))
+	flag.IntVar(&cfg.MinPlayers, "min-players", cfg.MinPlayers, "fewest players to start a game with")
+	flag.DurationVar(&cfg.ReadyTimeout, "ready-timeout", cfg.ReadyTimeout, "time matched players have to be ready, 0 to start games without a ready check")
+	flag.IntVar(&cfg.Countdown, "countdown", cfg.Countdown, "seconds counted down before a game starts once all players are ready")
+	flag.DurationVar(&cfg.LobbyWait, "lobby-wait", cfg.LobbyWait, "time to wait for a full room once min-players have joined")
+	flag.DurationVar(&cfg.BotWait, "bot-wait", cfg.BotWait, "time a player waits for others before bots fill the room, 0 for no bots")
+	flag.Float64Var(&cfg.BotSkill, "bot-skill", cfg.BotSkill, "how well bots play, from 0 (at random) to 1 (perfectly)")
+	flag.Var(&queues, "queue", "extra public queue as name=dir, or name=dir:min-max for levels with min to max boxes; may be repeated")
+	flag.StringVar(&cfg.RatingsFile, "ratings", "", "JSON file to keep player ratings in")
+	flag.StringVar(&cfg.AuthFile, "auth", "", "JSON file to keep the tokens of claimed nicknames in")
+	flag.StringVar(&cfg.AdminToken, "admin-token", "", "bearer token for the admin API at /admin/; the API is disabled without one")
+	flag.DurationVar(&cfg.GameTimeout, "game-timeout", cfg.GameTimeout, "longest time a game may be played")
+	flag.DurationVar(&cfg.GhostInterval, "ghost-interval", cfg.GhostInterval, "time between the moves of a ghost racing the solver's solution")
+	flag.DurationVar(&cfg.Limits.TimeLimit, "time", 0, "time limit for each game, e.g. 5m")
+	flag.IntVar(&cfg.Limits.MaxMoves, "moves", 0, "maximum number of moves per player")
+	flag.IntVar(&cfg.Limits.MaxPushes, "pushes", 0, "maximum number of box pushes per player")
+	flag.IntVar(&cfg.Schedule.MovesPerTurn, "turn", 0, "number of actions each player makes per turn; 0 for real-time play")
+}
+
 func main() {
 	log.Println("Sokoban websocket server")
-	websocket.Serve(&mock.BoardMaker3{})
+	flag.Parse()
+	if *configFile != "" {
+		if err := loadConfig(*configFile); err != nil {
+			log.Fatalf("Error reading config %s: %s", *configFile, err)
+		}
+	}
+	if cfg.Schedule.MovesPerTurn > 0 {
+		cfg.Schedule.Mode = sokoban.MultiMove
+	}
+
+	gen, err := levelSource()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, q := range queues {
+		queue, err := loadQueue(q)
+		if err != nil {
+			log.Fatalf("queue %s: %s", q, err)
+		}
+		cfg.Queues = append(cfg.Queues, queue)
+	}
+
+	if *records != "" {
+		cfg.Records, err = leaderboard.OpenFileStore(*records)
+		if err != nil {
+			log.Fatalf("Error reading records %s: %s", *records, err)
+		}
+	}
+	if *replays != "" {
+		cfg.Replays, err = replay.NewDirStore(*replays)
+		if err != nil {
+			log.Fatalf("Error opening replays %s: %s", *replays, err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = websocket.Serve(ctx, gen, cfg)
+	if err != nil {
+		log.Fatal("ListenAndServe: ", err)
+	}
+}
+
+// loadConfig sets each flag named in the JSON object of file to its value,
+// unless the flag was given on the command line
+func loadConfig(file string) error {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	// numbers are kept as written, as e.g. 1e+06 isn't a valid int flag
+	var values map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(content))
+	d.UseNumber()
+	if err := d.Decode(&values); err != nil {
+		return err
+	}
+
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for name, v := range values {
+		if given[name] {
+			continue
+		}
+		if err := flag.Set(name, fmt.Sprint(v)); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// levelSource returns the BoardMaker chosen by the level flags, naming the
+// levels in cfg
+func levelSource() (sokoban.BoardMaker, error) {
+	switch {
+	case *levelFile != "":
+		cfg.LevelName = filepath.Base(*levelFile)
+		content, err := ioutil.ReadFile(*levelFile)
+		if err != nil {
+			return nil, err
+		}
+		gen := &parse.JSONBoard{JSONContent: content}
+		// fail early on a malformed level
+		if _, err := gen.GenBoard(); err != nil {
+			return nil, fmt.Errorf("%s: %s", *levelFile, err)
+		}
+		return gen, nil
+	case *levelDir != "":
+		cfg.LevelName = filepath.Base(*levelDir)
+		return parse.LoadLevelDir(*levelDir)
+	}
+
+	cfg.LevelName = *generator
+	switch *generator {
+	case "mock1":
+		return mock.BoardMaker1{}, nil
+	case "mock2":
+		return mock.BoardMaker2{}, nil
+	case "mock3":
+		return mock.BoardMaker3{}, nil
+	}
+	return nil, fmt.Errorf("unknown level generator %q", *generator)
+}
+
+// queueFlags collects each -queue flag given
+type queueFlags []string
+
+func (q *queueFlags) String() string {
+	return strings.Join(*q, ",")
+}
+
+func (q *queueFlags) Set(value string) error {
+	*q = append(*q, value)
+	return nil
+}
+
+// bandPattern matches the box range of a -queue flag
+var bandPattern = regexp.MustCompile(`^\d+-\d+$`)
+
+// loadQueue makes the queue described by a -queue flag, e.g. "easy=levels:1-3"
+// for the levels in directory "levels" with 1 to 3 boxes
+func loadQueue(value string) (websocket.Queue, error) {
+	eq := strings.Index(value, "=")
+	if eq < 1 {
+		return websocket.Queue{}, errors.New("expected name=dir[:min-max]")
+	}
+	name, dir := value[:eq], value[eq+1:]
+	band := ""
+	if colon := strings.LastIndex(dir, ":"); colon >= 0 && bandPattern.MatchString(dir[colon+1:]) {
+		// other colons are part of the path, e.g. C:\levels
+		dir, band = dir[:colon], dir[colon+1:]
+	}
+
+	levels, err := parse.LoadLevelDir(dir)
+	if err != nil {
+		return websocket.Queue{}, err
+	}
+	q := websocket.Queue{Name: name, Level: filepath.Base(dir), Gen: levels}
+	if band == "" {
+		return q, nil
+	}
+	var min, max int
+	if _, err := fmt.Sscanf(band, "%d-%d", &min, &max); err != nil {
+		return websocket.Queue{}, fmt.Errorf("bad box range %q", band)
+	}
+	q.Gen, err = levels.Band(min, max)
+	q.Level = fmt.Sprintf("%s (%d-%d boxes)", q.Level, min, max)
+	return q, err
 }
@@ -1,5 +1,11 @@
 package sokoban
 
+import (
+	"context"
+	"errors"
+	"time"
+)
+
 // Interface and definitions for the Game object
 // Handles and reroutes actions on Boards from multiple players
 
@@ -7,6 +13,19 @@ package sokoban
 type Game struct {
 	boards  []*Board
 	control Controller
+	players []PlayerResult // outcome of each player, filled in during Play
+	order   []int          // players who have finished, first to last
+	start   time.Time
+
+	limits Limits
+	moves  []int // moves made by each player, including undone moves
+	pushes []int // box pushes made by each player, including undone pushes
+
+	schedule  Schedule
+	turn      int // player whose turn it is in turn-based games
+	turnMoves int // actions made so far in the current turn
+
+	observers []Observer
 }
 
 // Action represents a player's attempt on making a move
@@ -21,27 +40,45 @@ type ActionType int
 // Move: make move in Turn.Direction
 // Reset: set the board back to starting state
 // Undo: delete last move
+// Leave: player has left the game
 const (
 	Move  ActionType = 1
 	Reset ActionType = 2
 	Undo  ActionType = 3
+	Leave ActionType = 4
 )
 
-// Controller is interface for different types of input e.g. console, web
+// Controller is interface for different types of input e.g. console, web.
+// RecvInput should return early with ctx.Err() once ctx is done
 type Controller interface {
 	Init(*Board)
-	RecvInput() (int, Action)
+	RecvInput(ctx context.Context) (int, Action, error)
 	SendResult(player int, success bool, a Action)
 	OutputBoard(player int, b *Board)
 	Closing() bool
 }
 
+// ActionRejecter is optionally implemented by Controllers to tell players why
+// their action was rejected without being attempted. RejectAction is called
+// before SendResult reports the action as unsuccessful
+type ActionRejecter interface {
+	RejectAction(player int, a Action, reason error)
+}
+
+// ErrPlayerDone is the reason an action is rejected once the player has
+// finished, left or run out of time, moves or pushes
+var ErrPlayerDone = errors.New("sokoban: player has finished playing")
+
 // InitGame creates a Game instance with given number of players, controller and board generator
 // Returns error if unable to create Game
 func InitGame(nPlayers int, gen BoardMaker, c Controller) (*Game, error) {
 	g := &Game{
 		boards:  make([]*Board, nPlayers),
 		control: c,
+		players: make([]PlayerResult, nPlayers),
+		order:   make([]int, 0, nPlayers),
+		moves:   make([]int, nPlayers),
+		pushes:  make([]int, nPlayers),
 	}
 
 	var err error
@@ -57,26 +94,68 @@ func InitGame(nPlayers int, gen BoardMaker, c Controller) (*Game, error) {
 }
 
 // Play plays the Game on a loop, invoking Controller interface functions
-// until Closing() returns true
-func (g *Game) Play() {
+// until Closing() returns true. Returns the Result of the game
+func (g *Game) Play() Result {
+	return g.PlayContext(context.Background())
+}
+
+// PlayContext plays the Game like Play, additionally stopping when ctx is
+// cancelled or its deadline passes, when the time limit runs out, when every
+// player is done, or when the Controller fails to receive input. Players still
+// playing when the deadline passes have timed out
+func (g *Game) PlayContext(ctx context.Context) Result {
+	g.start = time.Now()
+	if g.limits.TimeLimit > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.limits.TimeLimit)
+		defer cancel()
+	}
+
 	// Broadcast starting board
 	g.control.Init(g.boards[0])
+	g.notify(GameStarted{g.start, len(g.boards), g.boards[0].Clone()})
+	for p := range g.boards {
+		g.reportLimits(p)
+	}
+	g.startTurns()
 
-	for !g.control.Closing() {
-		p, action := g.control.RecvInput()
+	for !g.allDone() && !g.control.Closing() {
+		p, action, err := g.control.RecvInput(ctx)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				g.timeOut()
+			}
+			break
+		}
 		if p < 0 || p >= len(g.boards) {
 			// invalid player num
 			continue
 		}
 
+		if action.Type == Leave {
+			g.playerDone(p, LeftGame)
+			g.endAction(p, false)
+			continue
+		}
+
 		var success bool
 
-		if g.boards[p].Won() {
+		if err := g.allowed(p); err != nil {
+			if r, ok := g.control.(ActionRejecter); ok {
+				r.RejectAction(p, action, err)
+			}
 			success = false
 		} else {
 			switch action.Type {
 			case Move:
+				pushes := g.boards[p].PushCount()
 				success = g.boards[p].MakeMove(action.Direction)
+				if success {
+					g.moves[p]++
+					if g.boards[p].PushCount() > pushes {
+						g.pushes[p]++
+					}
+				}
 			case Undo:
 				success = g.boards[p].UndoMove()
 			case Reset:
@@ -87,9 +166,90 @@ func (g *Game) Play() {
 			}
 		}
 
+		g.notify(ActionApplied{time.Now(), p, action, success, g.boards[p].Stats()})
+		if g.boards[p].Won() {
+			g.playerDone(p, Finished)
+		} else {
+			g.checkLimits(p)
+		}
+
 		g.control.SendResult(p, success, action)
 		g.control.OutputBoard(p, g.boards[p])
+		g.reportLimits(p)
+		g.endAction(p, success)
+	}
+
+	r := g.result()
+	g.notify(GameEnded{time.Now(), r})
+	return r
+}
+
+// allowed returns the reason player p may not act now, or nil if they may
+func (g *Game) allowed(p int) error {
+	if g.players[p].Done() {
+		return ErrPlayerDone
+	}
+	if !g.inTurn(p) {
+		return ErrNotYourTurn
+	}
+	return nil
+}
+
+// playerDone records the end of player p's game, if not already ended
+func (g *Game) playerDone(p int, status PlayerStatus) {
+	if g.players[p].Done() {
+		return
+	}
+	g.players[p].Status = status
+	g.players[p].Elapsed = time.Since(g.start)
+	switch status {
+	case Finished:
+		g.order = append(g.order, p)
+		g.players[p].Rank = len(g.order)
+		g.notify(PlayerWon{time.Now(), p, len(g.order), g.boards[p].Stats()})
+	case LeftGame:
+		g.notify(PlayerLeft{time.Now(), p})
+	case TimedOut:
+		g.notify(PlayerTimedOut{time.Now(), p})
+	case LimitReached:
+		g.notify(PlayerLimitReached{time.Now(), p, g.boards[p].Stats()})
+	}
+}
+
+// allDone returns whether no player can make any more moves
+func (g *Game) allDone() bool {
+	for _, p := range g.players {
+		if !p.Done() {
+			return false
+		}
+	}
+	return true
+}
+
+// timeOut marks every player still playing as having run out of time
+func (g *Game) timeOut() {
+	for p := range g.players {
+		g.playerDone(p, TimedOut)
+	}
+}
+
+// result collects the statistics of each player at the end of the game
+func (g *Game) result() Result {
+	r := Result{
+		Order:    make([]int, len(g.order)),
+		Players:  make([]PlayerResult, len(g.players)),
+		Duration: time.Since(g.start),
+	}
+	copy(r.Order, g.order)
+	for i, p := range g.players {
+		if !p.Done() {
+			p.Elapsed = r.Duration
+		}
+		p.Moves = g.boards[i].MoveCount()
+		p.Pushes = g.boards[i].PushCount()
+		r.Players[i] = p
 	}
+	return r
 }
 
 // ActionTypeToStr gets the name string of an ActionType
@@ -102,6 +262,8 @@ func ActionTypeToStr(t ActionType) string {
 		str = "undo"
 	case Reset:
 		str = "reset"
+	case Leave:
+		str = "leave"
 	}
 	return str
 }
@@ -1,7 +1,9 @@
 package sokoban_test
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/he-lium/sokoban"
 	"github.com/he-lium/sokoban/mock"
@@ -30,7 +32,7 @@ func TestGameSinglePlayer(t *testing.T) {
 		c.T.Fatalf("unable to init game: %s", err)
 	}
 
-	g.Play()
+	r := g.Play()
 
 	if c.InitInvoked != 1 {
 		c.T.Errorf("InitInvoked() was called %d times at end", c.InitInvoked)
@@ -41,4 +43,195 @@ func TestGameSinglePlayer(t *testing.T) {
 	if c.SendInvoked != len(c.Results) {
 		c.T.Errorf("c.SendInvoked is %d, expected %d", c.SendInvoked, len(c.Results))
 	}
+
+	if len(r.Order) != 1 || r.Order[0] != 0 {
+		c.T.Errorf("result order is %v, expected [0]", r.Order)
+	}
+	p := r.Players[0]
+	if p.Status != sokoban.Finished || p.Rank != 1 {
+		c.T.Errorf("player status %s rank %d, expected finished rank 1",
+			sokoban.PlayerStatusToStr(p.Status), p.Rank)
+	}
+	if p.Moves != 6 || p.Pushes != 1 {
+		c.T.Errorf("player made %d moves %d pushes, expected 6 moves 1 push",
+			p.Moves, p.Pushes)
+	}
+}
+
+func TestGameDeadline(t *testing.T) {
+	c := mock.Controller{T: t}
+	c.Actions = []sokoban.Action{
+		sokoban.Action{Type: sokoban.Move, Direction: sokoban.Up},
+	}
+	c.Results = []bool{true}
+	g, err := sokoban.InitGame(1, mock.BoardMaker3{}, &c)
+	if err != nil {
+		c.T.Fatalf("unable to init game: %s", err)
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	r := g.PlayContext(ctx)
+
+	if c.RecvInvoked != 0 {
+		c.T.Errorf("c.RecvInvoked is %d, expected 0", c.RecvInvoked)
+	}
+	if r.Players[0].Status != sokoban.TimedOut {
+		c.T.Errorf("player status %s, expected timed_out",
+			sokoban.PlayerStatusToStr(r.Players[0].Status))
+	}
+}
+
+func TestGameObserver(t *testing.T) {
+	c := mock.Controller{T: t}
+	c.Actions = []sokoban.Action{
+		sokoban.Action{Type: sokoban.Move, Direction: sokoban.Up},
+		sokoban.Action{Type: sokoban.Move, Direction: sokoban.Left},
+		sokoban.Action{Type: sokoban.Move, Direction: sokoban.Left},
+		sokoban.Action{Type: sokoban.Move, Direction: sokoban.Down},
+		sokoban.Action{Type: sokoban.Move, Direction: sokoban.Down},
+		sokoban.Action{Type: sokoban.Move, Direction: sokoban.Right},
+	}
+	c.Results = []bool{true, true, true, true, true, true}
+	g, err := sokoban.InitGame(1, mock.BoardMaker3{}, &c)
+	if err != nil {
+		c.T.Fatalf("unable to init game: %s", err)
+	}
+
+	var started, applied, won, ended int
+	g.AddObserver(sokoban.ObserverFunc(func(e sokoban.Event) {
+		switch e := e.(type) {
+		case sokoban.GameStarted:
+			started++
+		case sokoban.ActionApplied:
+			applied++
+			if e.Stats.Moves != applied {
+				c.T.Errorf("action %d: board has %d moves", applied, e.Stats.Moves)
+			}
+		case sokoban.PlayerWon:
+			won++
+			if e.Rank != 1 || !e.Stats.Won {
+				c.T.Errorf("player won with rank %d, board won %t", e.Rank, e.Stats.Won)
+			}
+		case sokoban.GameEnded:
+			ended++
+		}
+	}))
+	g.Play()
+
+	if started != 1 || applied != len(c.Actions) || won != 1 || ended != 1 {
+		c.T.Errorf("events: %d started, %d applied, %d won, %d ended",
+			started, applied, won, ended)
+	}
+}
+
+func TestGameMoveLimit(t *testing.T) {
+	c := mock.Controller{T: t}
+	c.Actions = []sokoban.Action{
+		sokoban.Action{Type: sokoban.Move, Direction: sokoban.Up},
+		sokoban.Action{Type: sokoban.Move, Direction: sokoban.Left},
+		sokoban.Action{Type: sokoban.Undo, Direction: -1},
+		sokoban.Action{Type: sokoban.Move, Direction: sokoban.Left},
+		sokoban.Action{Type: sokoban.Move, Direction: sokoban.Left},
+	}
+	c.Results = []bool{true, true, true, true, true}
+	g, err := sokoban.InitGame(1, mock.BoardMaker3{}, &c)
+	if err != nil {
+		c.T.Fatalf("unable to init game: %s", err)
+	}
+	g.SetLimits(sokoban.Limits{MaxMoves: 3})
+	var limited []sokoban.PlayerLimitReached
+	g.AddObserver(sokoban.ObserverFunc(func(e sokoban.Event) {
+		if e, ok := e.(sokoban.PlayerLimitReached); ok {
+			limited = append(limited, e)
+		}
+	}))
+
+	r := g.Play()
+
+	// undone moves still count towards the limit
+	if c.RecvInvoked != 4 {
+		c.T.Errorf("c.RecvInvoked is %d, expected 4", c.RecvInvoked)
+	}
+	if r.Players[0].Status != sokoban.LimitReached {
+		c.T.Errorf("player status %s, expected limit_reached",
+			sokoban.PlayerStatusToStr(r.Players[0].Status))
+	}
+	if r.Players[0].Rank != 0 || len(r.Order) != 0 {
+		c.T.Errorf("player ranked %d, expected unranked", r.Players[0].Rank)
+	}
+	if len(limited) != 1 || limited[0].Player != 0 || limited[0].Stats.Moves != 2 {
+		c.T.Errorf("limit reached events %+v, expected one for player 0", limited)
+	}
+}
+
+// turnController is a Controller for two players which sends each player's
+// actions in the given order, recording the results and turns
+type turnController struct {
+	mock.Controller
+	players []int
+	turns   []int
+	success []bool
+	rejects []error
+}
+
+func (c *turnController) RecvInput(ctx context.Context) (int, sokoban.Action, error) {
+	_, a, err := c.Controller.RecvInput(ctx)
+	return c.players[c.RecvInvoked-1], a, err
+}
+
+func (c *turnController) SendResult(p int, success bool, a sokoban.Action) {
+	c.success = append(c.success, success)
+}
+
+func (c *turnController) OutputBoard(p int, b *sokoban.Board) {}
+
+func (c *turnController) NotifyTurn(p int) {
+	c.turns = append(c.turns, p)
+}
+
+func (c *turnController) RejectAction(p int, a sokoban.Action, reason error) {
+	c.rejects = append(c.rejects, reason)
+}
+
+func TestGameAlternateTurns(t *testing.T) {
+	c := &turnController{}
+	c.T = t
+	c.Actions = []sokoban.Action{
+		sokoban.Action{Type: sokoban.Move, Direction: sokoban.Up},
+		sokoban.Action{Type: sokoban.Move, Direction: sokoban.Up},
+		sokoban.Action{Type: sokoban.Move, Direction: sokoban.Right},
+		sokoban.Action{Type: sokoban.Move, Direction: sokoban.Up},
+		sokoban.Action{Type: sokoban.Move, Direction: sokoban.Left},
+	}
+	c.Results = make([]bool, len(c.Actions))
+	c.players = []int{0, 0, 1, 1, 0}
+	g, err := sokoban.InitGame(2, mock.BoardMaker3{}, c)
+	if err != nil {
+		t.Fatalf("unable to init game: %s", err)
+	}
+	g.SetSchedule(sokoban.Schedule{Mode: sokoban.Alternate})
+
+	g.Play()
+
+	// player 0 may not move twice; player 1 hits a wall without losing turn
+	expectSuccess := []bool{true, false, false, true, true}
+	for i, s := range expectSuccess {
+		if c.success[i] != s {
+			t.Errorf("action %d success %t, expected %t", i, c.success[i], s)
+		}
+	}
+	if len(c.rejects) != 1 || c.rejects[0] != sokoban.ErrNotYourTurn {
+		t.Errorf("rejected actions for %v, expected [%v]", c.rejects, sokoban.ErrNotYourTurn)
+	}
+	expectTurns := []int{0, 1, 0, 1}
+	if len(c.turns) != len(expectTurns) {
+		t.Fatalf("turns were %v, expected %v", c.turns, expectTurns)
+	}
+	for i, p := range expectTurns {
+		if c.turns[i] != p {
+			t.Errorf("turns were %v, expected %v", c.turns, expectTurns)
+			break
+		}
+	}
 }
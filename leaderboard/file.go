@@ -0,0 +1,103 @@
+package leaderboard
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileStore is a Store kept in a local file, one JSON record per line. The
+// records are held in memory and each new record is appended to the file
+type FileStore struct {
+	file    string
+	lock    sync.Mutex
+	byLevel map[string][]Record
+}
+
+// ensure Store interface is implemented
+var _ Store = (*FileStore)(nil)
+
+// fileRecord is a Record as written to the file
+type fileRecord struct {
+	Level  string    `json:"level"`
+	Player string    `json:"player"`
+	Moves  int       `json:"moves"`
+	Pushes int       `json:"pushes"`
+	TimeMS int64     `json:"time_ms"`
+	Date   time.Time `json:"date"`
+}
+
+// OpenFileStore loads the records saved in file, which is created once the
+// first record is added
+func OpenFileStore(file string) (*FileStore, error) {
+	s := &FileStore{file: file, byLevel: make(map[string][]Record)}
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var fr fileRecord
+		if err := json.Unmarshal(scanner.Bytes(), &fr); err != nil {
+			return nil, err
+		}
+		r := Record{fr.Level, fr.Player, fr.Moves, fr.Pushes,
+			time.Duration(fr.TimeMS) * time.Millisecond, fr.Date}
+		s.byLevel[r.Level] = append(s.byLevel[r.Level], r)
+	}
+	return s, scanner.Err()
+}
+
+// Add appends r to the file
+func (s *FileStore) Add(r Record) error {
+	line, err := json.Marshal(fileRecord{r.Level, r.Player, r.Moves, r.Pushes,
+		r.Time.Milliseconds(), r.Date})
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	f, err := os.OpenFile(s.file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	s.byLevel[r.Level] = append(s.byLevel[r.Level], r)
+	return nil
+}
+
+// Records returns every record of the level, in the order they were added
+func (s *FileStore) Records(level string) ([]Record, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Record(nil), s.byLevel[level]...), nil
+}
+
+// Levels returns the hash of every level with records, in sorted order
+func (s *FileStore) Levels() ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	levels := make([]string, 0, len(s.byLevel))
+	for level := range s.byLevel {
+		levels = append(levels, level)
+	}
+	sort.Strings(levels)
+	return levels, nil
+}
@@ -0,0 +1,170 @@
+package leaderboard
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/he-lium/sokoban"
+)
+
+// Leaderboards rank the players who solved a level by their fastest time,
+// fewest moves or fewest pushes. Levels are identified by a hash of their
+// starting board, so the same level is ranked together whichever file or
+// generator it came from
+
+// Record holds a player's solution of a level
+type Record struct {
+	Level  string        // hash of the level's starting board
+	Player string        // nickname of the player
+	Moves  int           // moves in the solution
+	Pushes int           // box pushes in the solution
+	Time   time.Duration // time taken to solve the board
+	Date   time.Time     // when the board was solved
+}
+
+// Store saves Records. Implementations must be safe for use by concurrent
+// games
+type Store interface {
+	Add(r Record) error
+	Records(level string) ([]Record, error) // every record of the level
+	Levels() ([]string, error)              // hash of every level with records
+}
+
+// Ranking is the statistic a leaderboard is ordered by
+type Ranking int
+
+// ByTime: fastest time first
+// ByMoves: fewest moves first
+// ByPushes: fewest pushes first
+const (
+	ByTime   Ranking = iota
+	ByMoves  Ranking = iota
+	ByPushes Ranking = iota
+)
+
+// Rankings lists every Ranking
+var Rankings = []Ranking{ByTime, ByMoves, ByPushes}
+
+// RankingToStr gets the name string of a Ranking
+func RankingToStr(by Ranking) string {
+	switch by {
+	case ByTime:
+		return "time"
+	case ByMoves:
+		return "moves"
+	case ByPushes:
+		return "pushes"
+	default:
+		return "?"
+	}
+}
+
+// StrToRanking gets the Ranking named by s, returning false if there is none
+func StrToRanking(s string) (Ranking, bool) {
+	for _, by := range Rankings {
+		if RankingToStr(by) == s {
+			return by, true
+		}
+	}
+	return 0, false
+}
+
+// better determines whether a ranks above b, breaking ties by the other
+// statistics and then by who solved the level first
+func better(a, b Record, by Ranking) bool {
+	stats := func(r Record) []int64 {
+		switch by {
+		case ByMoves:
+			return []int64{int64(r.Moves), int64(r.Pushes), int64(r.Time)}
+		case ByPushes:
+			return []int64{int64(r.Pushes), int64(r.Moves), int64(r.Time)}
+		default:
+			return []int64{int64(r.Time), int64(r.Moves), int64(r.Pushes)}
+		}
+	}
+	sa, sb := stats(a), stats(b)
+	for i := range sa {
+		if sa[i] != sb[i] {
+			return sa[i] < sb[i]
+		}
+	}
+	return a.Date.Before(b.Date)
+}
+
+// Top returns the best record of each player, ordered by the given Ranking
+// and cut to at most n records. n <= 0 returns every player
+func Top(records []Record, by Ranking, n int) []Record {
+	best := make(map[string]Record)
+	for _, r := range records {
+		if b, ok := best[r.Player]; !ok || better(r, b, by) {
+			best[r.Player] = r
+		}
+	}
+	top := make([]Record, 0, len(best))
+	for _, r := range best {
+		top = append(top, r)
+	}
+	sort.Slice(top, func(i, j int) bool { return better(top[i], top[j], by) })
+	if n > 0 && len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// PersonalBests returns the Rankings in which r beats every earlier record of
+// its player, given the records of the level. A player's first solution is a
+// personal best in every Ranking
+func PersonalBests(records []Record, r Record) []Ranking {
+	var bests []Ranking
+	for _, by := range Rankings {
+		best := true
+		for _, prev := range records {
+			if prev.Player != r.Player {
+				continue
+			}
+			switch by {
+			case ByTime:
+				best = best && r.Time < prev.Time
+			case ByMoves:
+				best = best && r.Moves < prev.Moves
+			case ByPushes:
+				best = best && r.Pushes < prev.Pushes
+			}
+		}
+		if best {
+			bests = append(bests, by)
+		}
+	}
+	return bests
+}
+
+// LevelHash identifies a level by its starting board b, ignoring the board's
+// ID
+func LevelHash(b *sokoban.Board) string {
+	var s strings.Builder
+	for y := 0; y < b.Height; y++ {
+		for x := 0; x < b.Width; x++ {
+			item := b.Grid[x][y]
+			switch {
+			case b.Player.X == x && b.Player.Y == y:
+				s.WriteByte('@')
+			case item.ItemType == sokoban.Wall:
+				s.WriteByte('#')
+			case item.ContainsBox && item.ItemType == sokoban.Target:
+				s.WriteByte('*')
+			case item.ContainsBox:
+				s.WriteByte('$')
+			case item.ItemType == sokoban.Target:
+				s.WriteByte('.')
+			default:
+				s.WriteByte(' ')
+			}
+		}
+		s.WriteByte('\n')
+	}
+	sum := sha256.Sum256([]byte(s.String()))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,110 @@
+package leaderboard_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/he-lium/sokoban/leaderboard"
+	"github.com/he-lium/sokoban/mock"
+)
+
+var day = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var records = []leaderboard.Record{
+	{"l", "ann", 20, 5, 30 * time.Second, day},
+	{"l", "bob", 14, 6, 50 * time.Second, day.Add(time.Hour)},
+	{"l", "ann", 16, 4, 40 * time.Second, day.Add(2 * time.Hour)},
+	{"l", "cat", 14, 6, 45 * time.Second, day.Add(3 * time.Hour)},
+}
+
+func TestTop(t *testing.T) {
+	players := func(rs []leaderboard.Record) []string {
+		names := make([]string, len(rs))
+		for i, r := range rs {
+			names[i] = r.Player
+		}
+		return names
+	}
+
+	top := leaderboard.Top(records, leaderboard.ByTime, 0)
+	if !reflect.DeepEqual(players(top), []string{"ann", "cat", "bob"}) {
+		t.Errorf("by time got %v", top)
+	}
+	if top[0].Time != 30*time.Second {
+		t.Errorf("ann's best time is %v, expected 30s", top[0].Time)
+	}
+	// tied on moves and pushes: cat is faster
+	top = leaderboard.Top(records, leaderboard.ByMoves, 2)
+	if !reflect.DeepEqual(players(top), []string{"cat", "bob"}) {
+		t.Errorf("top 2 by moves got %v", top)
+	}
+	top = leaderboard.Top(records, leaderboard.ByPushes, 1)
+	if len(top) != 1 || top[0].Player != "ann" || top[0].Pushes != 4 {
+		t.Errorf("top by pushes got %v", top)
+	}
+}
+
+func TestPersonalBests(t *testing.T) {
+	r := leaderboard.Record{"l", "ann", 15, 5, 35 * time.Second, day.Add(4 * time.Hour)}
+	best := leaderboard.PersonalBests(records, r)
+	if !reflect.DeepEqual(best, []leaderboard.Ranking{leaderboard.ByMoves}) {
+		t.Errorf("got personal bests %v, expected only moves", best)
+	}
+	r.Player = "dan"
+	if best := leaderboard.PersonalBests(records, r); len(best) != len(leaderboard.Rankings) {
+		t.Errorf("first solution is best in %v, expected every ranking", best)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "records.jsonl")
+	s, err := leaderboard.OpenFileStore(file)
+	if err != nil {
+		t.Fatalf("error opening new store: %s", err.Error())
+	}
+	for _, r := range records {
+		if err := s.Add(r); err != nil {
+			t.Fatalf("error adding record: %s", err.Error())
+		}
+	}
+	if err := s.Add(leaderboard.Record{Level: "k", Player: "ann", Date: day}); err != nil {
+		t.Fatalf("error adding record: %s", err.Error())
+	}
+
+	// records survive reopening the file
+	s, err = leaderboard.OpenFileStore(file)
+	if err != nil {
+		t.Fatalf("error reopening store: %s", err.Error())
+	}
+	levels, _ := s.Levels()
+	if !reflect.DeepEqual(levels, []string{"k", "l"}) {
+		t.Errorf("got levels %v, expected [k l]", levels)
+	}
+	got, _ := s.Records("l")
+	if len(got) != len(records) {
+		t.Fatalf("got %d records, expected %d", len(got), len(records))
+	}
+	for i, r := range got {
+		if r.Date.Equal(records[i].Date) {
+			r.Date = records[i].Date
+		}
+		if r != records[i] {
+			t.Errorf("record %d is %v, expected %v", i, r, records[i])
+		}
+	}
+}
+
+func TestLevelHash(t *testing.T) {
+	b1, _ := mock.BoardMaker3{}.GenBoard()
+	b2, _ := mock.BoardMaker3{}.GenBoard()
+	b2.ID = 7
+	if leaderboard.LevelHash(b1) != leaderboard.LevelHash(b2) {
+		t.Error("same level with different IDs hashed differently")
+	}
+	other, _ := mock.BoardMaker2{}.GenBoard()
+	if leaderboard.LevelHash(b1) == leaderboard.LevelHash(other) {
+		t.Error("different levels hashed the same")
+	}
+}
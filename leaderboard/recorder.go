@@ -0,0 +1,65 @@
+package leaderboard
+
+import (
+	"log"
+	"time"
+
+	"github.com/he-lium/sokoban"
+)
+
+// BestNotifier is optionally implemented by Controllers to tell players when
+// they set a new personal best
+type BestNotifier interface {
+	NotifyBest(player int, best []Ranking)
+}
+
+// Recorder is a sokoban.Observer which adds a Record to a Store whenever a
+// named player solves their board
+type Recorder struct {
+	store    Store
+	names    []string     // nickname of each player, empty to not record
+	notifier BestNotifier // told of personal bests, may be nil
+	level    string       // hash of the starting board
+	start    time.Time
+}
+
+// ensure sokoban.Observer interface is implemented
+var _ sokoban.Observer = (*Recorder)(nil)
+
+// NewRecorder creates a Recorder adding the solutions of the named players to
+// s. If n is not nil, it is notified of each personal best
+func NewRecorder(s Store, names []string, n BestNotifier) *Recorder {
+	return &Recorder{store: s, names: names, notifier: n}
+}
+
+// Notify records the solution of a player who has won
+func (r *Recorder) Notify(e sokoban.Event) {
+	switch e := e.(type) {
+	case sokoban.GameStarted:
+		r.level = LevelHash(e.Board)
+		r.start = e.Time
+	case sokoban.PlayerWon:
+		if e.Player >= len(r.names) || r.names[e.Player] == "" {
+			return
+		}
+		rec := Record{
+			Level:  r.level,
+			Player: r.names[e.Player],
+			Moves:  e.Stats.Moves,
+			Pushes: e.Stats.Pushes,
+			Time:   e.Time.Sub(r.start),
+			Date:   e.Time,
+		}
+		records, err := r.store.Records(r.level)
+		if err == nil {
+			err = r.store.Add(rec)
+		}
+		if err != nil {
+			log.Printf("leaderboard: ERROR recording %s on %s: %s\n", rec.Player, rec.Level, err.Error())
+			return
+		}
+		if best := PersonalBests(records, rec); len(best) > 0 && r.notifier != nil {
+			r.notifier.NotifyBest(e.Player, best)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package sokoban
+
+import "time"
+
+// Limits restricts how long and how much each player of a Game may play.
+// Zero fields are unlimited
+type Limits struct {
+	TimeLimit time.Duration // wall-clock time from the start of the game
+	MaxMoves  int           // moves each player may make, including undone ones
+	MaxPushes int           // box pushes each player may make
+}
+
+// LimitStatus is a player's remaining allowance in a limited Game.
+// Fields are -1 where there is no limit
+type LimitStatus struct {
+	TimeLeft   time.Duration
+	MovesLeft  int
+	PushesLeft int
+}
+
+// LimitReporter is optionally implemented by Controllers to show players their
+// remaining allowance. ReportLimits is called for every player at the start of
+// a limited game, and after each of the player's actions
+type LimitReporter interface {
+	ReportLimits(player int, s LimitStatus)
+}
+
+// SetLimits restricts the time, moves and pushes available to each player.
+// Must be called before Play
+func (g *Game) SetLimits(l Limits) {
+	g.limits = l
+}
+
+// limitStatus returns the remaining allowance of player p
+func (g *Game) limitStatus(p int) LimitStatus {
+	s := LimitStatus{-1, -1, -1}
+	if g.limits.TimeLimit > 0 {
+		s.TimeLeft = g.limits.TimeLimit - time.Since(g.start)
+		if s.TimeLeft < 0 {
+			s.TimeLeft = 0
+		}
+	}
+	if g.limits.MaxMoves > 0 {
+		s.MovesLeft = g.limits.MaxMoves - g.moves[p]
+	}
+	if g.limits.MaxPushes > 0 {
+		s.PushesLeft = g.limits.MaxPushes - g.pushes[p]
+	}
+	return s
+}
+
+// checkLimits ends player p's game if they have used up their moves or pushes
+func (g *Game) checkLimits(p int) {
+	s := g.limitStatus(p)
+	if s.MovesLeft == 0 || s.PushesLeft == 0 {
+		g.playerDone(p, LimitReached)
+	}
+}
+
+// reportLimits sends player p's remaining allowance to the Controller, if the
+// game is limited and the Controller is a LimitReporter
+func (g *Game) reportLimits(p int) {
+	if g.limits == (Limits{}) {
+		return
+	}
+	if r, ok := g.control.(LimitReporter); ok {
+		r.ReportLimits(p, g.limitStatus(p))
+	}
+}
@@ -0,0 +1,325 @@
+// Package metrics collects counters, gauges and histograms and serves them in
+// the Prometheus text exposition format, using only the standard library
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ContentType is the media type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Registry holds metrics in the order they were created. Safe for concurrent
+// use
+type Registry struct {
+	lock    sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+// metric is a named metric which reports its current samples
+type metric interface {
+	describe() (name, help, kind string)
+	samples() []sample
+}
+
+// sample is one line of a metric: its value, a suffix of the metric name and
+// any label name and value pairs
+type sample struct {
+	suffix string
+	labels []string
+	value  float64
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// register adds m to r. Panics if its name is taken, as that is a programming
+// error
+func (r *Registry) register(m metric) {
+	name, _, _ := m.describe()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every metric of r to w in the text exposition format
+func (r *Registry) Write(w io.Writer) error {
+	r.lock.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.lock.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		name, help, kind := m.describe()
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, escapeHelp(help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, kind)
+		for _, s := range m.samples() {
+			bw.WriteString(name + s.suffix)
+			if len(s.labels) > 0 {
+				bw.WriteByte('{')
+				for i := 0; i < len(s.labels); i += 2 {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					fmt.Fprintf(bw, "%s=\"%s\"", s.labels[i], escapeLabel(s.labels[i+1]))
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteString(" " + formatValue(s.value) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics of r to a scraper
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	r.Write(w)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Counter is a count which only goes up, such as the number of games played
+type Counter struct {
+	name, help string
+	n          uint64
+}
+
+// NewCounter creates a Counter in r
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to c
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.n, 1)
+}
+
+// Add adds n to c
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.n, n)
+}
+
+// Value returns the count of c
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.n)
+}
+
+func (c *Counter) describe() (string, string, string) {
+	return c.name, c.help, "counter"
+}
+
+func (c *Counter) samples() []sample {
+	return []sample{{value: float64(c.Value())}}
+}
+
+// CounterVec is a set of Counters told apart by the value of one label, such
+// as the number of actions of each type
+type CounterVec struct {
+	name, help string
+	label      string
+	lock       sync.Mutex
+	counters   map[string]*Counter
+}
+
+// NewCounterVec creates a CounterVec in r with the given label name
+func (r *Registry) NewCounterVec(name, help, label string) *CounterVec {
+	v := &CounterVec{name: name, help: help, label: label, counters: make(map[string]*Counter)}
+	r.register(v)
+	return v
+}
+
+// With returns the Counter of the label value, creating it if needed
+func (v *CounterVec) With(value string) *Counter {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	c, ok := v.counters[value]
+	if !ok {
+		c = &Counter{}
+		v.counters[value] = c
+	}
+	return c
+}
+
+func (v *CounterVec) describe() (string, string, string) {
+	return v.name, v.help, "counter"
+}
+
+func (v *CounterVec) samples() []sample {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	values := make([]string, 0, len(v.counters))
+	for value := range v.counters {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	samples := make([]sample, len(values))
+	for i, value := range values {
+		samples[i] = sample{labels: []string{v.label, value}, value: float64(v.counters[value].Value())}
+	}
+	return samples
+}
+
+// Gauge is a value which goes up and down, such as the number of connected
+// clients
+type Gauge struct {
+	name, help string
+	bits       uint64
+}
+
+// NewGauge creates a Gauge in r
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	r.register(g)
+	return g
+}
+
+// Set sets the value of g
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Add adds delta to the value of g
+func (g *Gauge) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		next := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&g.bits, old, next) {
+			return
+		}
+	}
+}
+
+// Inc adds one to g
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+// Dec subtracts one from g
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+// Value returns the value of g
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+func (g *Gauge) describe() (string, string, string) {
+	return g.name, g.help, "gauge"
+}
+
+func (g *Gauge) samples() []sample {
+	return []sample{{value: g.Value()}}
+}
+
+// gaugeFunc is a gauge whose value is worked out when scraped
+type gaugeFunc struct {
+	name, help string
+	f          func() float64
+}
+
+// NewGaugeFunc creates a gauge in r whose value is returned by f when
+// scraped. f must be safe to call from any goroutine
+func (r *Registry) NewGaugeFunc(name, help string, f func() float64) {
+	r.register(&gaugeFunc{name, help, f})
+}
+
+func (g *gaugeFunc) describe() (string, string, string) {
+	return g.name, g.help, "gauge"
+}
+
+func (g *gaugeFunc) samples() []sample {
+	return []sample{{value: g.f()}}
+}
+
+// Histogram counts observations, such as round-trip times, in buckets of
+// values up to each of its upper bounds
+type Histogram struct {
+	name, help string
+	bounds     []float64
+	lock       sync.Mutex
+	counts     []uint64 // observations in each bucket, the last above every bound
+	count      uint64
+	sum        float64
+}
+
+// NewHistogram creates a Histogram in r with buckets up to each of the bounds
+func (r *Registry) NewHistogram(name, help string, bounds []float64) *Histogram {
+	bounds = append([]float64(nil), bounds...)
+	sort.Float64s(bounds)
+	h := &Histogram{name: name, help: help, bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+	r.register(h)
+	return h
+}
+
+// Observe adds v to h
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.counts[i]++
+	h.count++
+	h.sum += v
+}
+
+func (h *Histogram) describe() (string, string, string) {
+	return h.name, h.help, "histogram"
+}
+
+func (h *Histogram) samples() []sample {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	samples := make([]sample, 0, len(h.bounds)+3)
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		samples = append(samples, sample{"_bucket", []string{"le", formatValue(bound)}, float64(cumulative)})
+	}
+	return append(samples,
+		sample{"_bucket", []string{"le", "+Inf"}, float64(h.count)},
+		sample{"_sum", nil, h.sum},
+		sample{"_count", nil, float64(h.count)},
+	)
+}
@@ -0,0 +1,94 @@
+package metrics_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/he-lium/sokoban/metrics"
+)
+
+func TestWrite(t *testing.T) {
+	r := metrics.NewRegistry()
+	games := r.NewCounter("games_total", "Games played.")
+	actions := r.NewCounterVec("actions_total", "Actions by type.", "type")
+	clients := r.NewGauge("clients", "Connected clients.")
+	r.NewGaugeFunc("rooms", "Open rooms.", func() float64 { return 2 })
+	rtt := r.NewHistogram("rtt_seconds", "Round trip\ntime.", []float64{0.5, 0.1})
+
+	games.Inc()
+	games.Add(2)
+	actions.With("undo").Inc()
+	actions.With("move").Add(4)
+	actions.With(`a"b`).Inc()
+	clients.Inc()
+	clients.Inc()
+	clients.Dec()
+	clients.Add(0.5)
+	rtt.Observe(0.05)
+	rtt.Observe(0.1)
+	rtt.Observe(0.2)
+	rtt.Observe(3)
+
+	want := `# HELP games_total Games played.
+# TYPE games_total counter
+games_total 3
+# HELP actions_total Actions by type.
+# TYPE actions_total counter
+actions_total{type="a\"b"} 1
+actions_total{type="move"} 4
+actions_total{type="undo"} 1
+# HELP clients Connected clients.
+# TYPE clients gauge
+clients 1.5
+# HELP rooms Open rooms.
+# TYPE rooms gauge
+rooms 2
+# HELP rtt_seconds Round trip\ntime.
+# TYPE rtt_seconds histogram
+rtt_seconds_bucket{le="0.1"} 2
+rtt_seconds_bucket{le="0.5"} 3
+rtt_seconds_bucket{le="+Inf"} 4
+rtt_seconds_sum 3.35
+rtt_seconds_count 4
+`
+	var b bytes.Buffer
+	if err := r.Write(&b); err != nil {
+		t.Fatalf("error writing metrics: %s", err.Error())
+	}
+	if b.String() != want {
+		t.Errorf("wrote\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.NewCounter("games_total", "")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic registering a duplicate metric")
+		}
+	}()
+	r.NewGauge("games_total", "")
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.NewCounter("games_total", "Games played.").Inc()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != metrics.ContentType {
+		t.Errorf("got status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !bytes.Contains(w.Body.Bytes(), []byte("games_total 1\n")) {
+		t.Errorf("body missing counter:\n%s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST got status %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
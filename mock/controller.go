@@ -1,6 +1,7 @@
 package mock
 
 import (
+	"context"
 	"testing"
 
 	"github.com/he-lium/sokoban"
@@ -35,10 +36,13 @@ func (c *Controller) Init(b *sokoban.Board) {
 	}
 }
 
-func (c *Controller) RecvInput() (int, sokoban.Action) {
+func (c *Controller) RecvInput(ctx context.Context) (int, sokoban.Action, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, sokoban.Action{}, err
+	}
 	a := c.Actions[c.RecvInvoked]
 	c.RecvInvoked++
-	return 0, a
+	return 0, a, nil
 }
 
 func (c *Controller) SendResult(p int, success bool, a sokoban.Action) {
@@ -0,0 +1,105 @@
+package sokoban
+
+import "time"
+
+// Observers are notified of the events of a Game as it is played, e.g. for
+// logging, recording replays or collecting metrics. Observers are invoked
+// synchronously by Play and should not block
+
+// Observer receives Events from a Game
+type Observer interface {
+	Notify(e Event)
+}
+
+// ObserverFunc adapts an ordinary function to the Observer interface
+type ObserverFunc func(e Event)
+
+// Notify calls f(e)
+func (f ObserverFunc) Notify(e Event) {
+	f(e)
+}
+
+// Event is one of GameStarted, TurnStarted, ActionApplied, PlayerWon,
+// PlayerLeft, PlayerTimedOut, PlayerLimitReached or GameEnded
+type Event interface {
+	event()
+}
+
+// GameStarted is sent once the starting board has been sent to the Controller
+type GameStarted struct {
+	Time     time.Time
+	NPlayers int
+	Board    *Board // copy of the starting board
+}
+
+// TurnStarted is sent when the turn passes to a player in turn-based games
+type TurnStarted struct {
+	Time   time.Time
+	Player int
+}
+
+// ActionApplied is sent after a player's action has been processed
+type ActionApplied struct {
+	Time    time.Time
+	Player  int
+	Action  Action
+	Success bool
+	Stats   BoardStats // stats of the player's board after the action
+}
+
+// PlayerWon is sent when a player solves their board
+type PlayerWon struct {
+	Time   time.Time
+	Player int
+	Rank   int // 1-based finishing position
+	Stats  BoardStats
+}
+
+// PlayerLeft is sent when a player leaves the game before solving the board
+type PlayerLeft struct {
+	Time   time.Time
+	Player int
+}
+
+// PlayerTimedOut is sent when a player runs out of time before solving the
+// board
+type PlayerTimedOut struct {
+	Time   time.Time
+	Player int
+}
+
+// PlayerLimitReached is sent when a player uses up their moves or pushes
+// before solving the board
+type PlayerLimitReached struct {
+	Time   time.Time
+	Player int
+	Stats  BoardStats
+}
+
+// GameEnded is sent once Play has finished
+type GameEnded struct {
+	Time   time.Time
+	Result Result
+}
+
+func (GameStarted) event()        {}
+func (TurnStarted) event()        {}
+func (ActionApplied) event()      {}
+func (PlayerWon) event()          {}
+func (PlayerLeft) event()         {}
+func (PlayerTimedOut) event()     {}
+func (PlayerLimitReached) event() {}
+func (GameEnded) event()          {}
+
+// AddObserver registers o to receive the events of the Game. Must be called
+// before Play
+func (g *Game) AddObserver(o Observer) {
+	g.observers = append(g.observers, o)
+}
+
+// notify sends e to every Observer
+func (g *Game) notify(e Event) {
+	for _, o := range g.observers {
+		o.Notify(e)
+	}
+}
@@ -0,0 +1,71 @@
+package parse
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/he-lium/sokoban"
+)
+
+// for generating the JSON served by the admin API
+
+// AdminClient is a client waiting for a game to start
+type AdminClient struct {
+	Name    string `json:"name"` // empty if not joined
+	Rating  int    `json:"rating"`
+	Queue   string `json:"queue"`
+	Room    string `json:"room,omitempty"` // join code of their private room, if any
+	Matched bool   `json:"matched"`        // waiting for their match to be ready
+	Bot     bool   `json:"bot"`
+}
+
+// AdminGame is a game in progress
+type AdminGame struct {
+	ID         int           `json:"id"`
+	Level      string        `json:"level"`
+	Started    time.Time     `json:"started"`
+	Spectators int           `json:"spectators"`
+	Players    []AdminPlayer `json:"players"`
+}
+
+// AdminPlayer is a player of a game in progress, with their current board
+type AdminPlayer struct {
+	Player    int        `json:"player"`
+	Name      string     `json:"name"` // empty if not joined
+	Bot       bool       `json:"bot"`  // played by the server: a bot or ghost
+	Connected bool       `json:"connected"`
+	Won       bool       `json:"won"`
+	Left      bool       `json:"left"`
+	Board     BoardState `json:"board"`
+}
+
+// NewBoardState describes the current state of a player's board
+func NewBoardState(b *sokoban.Board) BoardState {
+	s := b.Stats()
+	return BoardState{
+		convertFromBoard(b),
+		BoardStats{s.Moves, s.Pushes, s.Score, s.Targets, s.Won},
+	}
+}
+
+// AdminClientsJSON generates JSON listing the clients waiting for a game
+func AdminClientsJSON(clients []AdminClient) []byte {
+	if clients == nil {
+		clients = make([]AdminClient, 0)
+	}
+	j, _ := json.Marshal(struct {
+		Clients []AdminClient `json:"clients"`
+	}{clients})
+	return j
+}
+
+// AdminGamesJSON generates JSON listing the games in progress
+func AdminGamesJSON(games []AdminGame) []byte {
+	if games == nil {
+		games = make([]AdminGame, 0)
+	}
+	j, _ := json.Marshal(struct {
+		Games []AdminGame `json:"games"`
+	}{games})
+	return j
+}
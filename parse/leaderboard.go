@@ -0,0 +1,51 @@
+package parse
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/he-lium/sokoban/leaderboard"
+)
+
+// for generating the JSON leaderboards served over HTTP
+
+// LeaderboardEntry is a player's best solution of a level
+type LeaderboardEntry struct {
+	Rank   int       `json:"rank"`
+	Player string    `json:"player"`
+	Moves  int       `json:"moves"`
+	Pushes int       `json:"pushes"`
+	TimeMS int64     `json:"time_ms"`
+	Date   time.Time `json:"date"`
+}
+
+// Leaderboard ranks the players of a level by one statistic
+type Leaderboard struct {
+	Level   string             `json:"level"`
+	By      string             `json:"by"` // "time", "moves" or "pushes"
+	Entries []LeaderboardEntry `json:"entries"`
+}
+
+// LeaderboardJSON generates JSON for the leaderboard of level, given the top
+// records in order
+func LeaderboardJSON(level string, by leaderboard.Ranking, top []leaderboard.Record) []byte {
+	l := Leaderboard{level, leaderboard.RankingToStr(by), make([]LeaderboardEntry, len(top))}
+	for i, r := range top {
+		l.Entries[i] = LeaderboardEntry{i + 1, r.Player, r.Moves, r.Pushes,
+			int64(r.Time / time.Millisecond), r.Date}
+	}
+	j, _ := json.Marshal(l)
+	return j
+}
+
+// LeaderboardLevelsJSON generates JSON listing the hash of every level with a
+// leaderboard
+func LeaderboardLevelsJSON(levels []string) []byte {
+	if levels == nil {
+		levels = make([]string, 0)
+	}
+	j, _ := json.Marshal(struct {
+		Levels []string `json:"levels"`
+	}{levels})
+	return j
+}
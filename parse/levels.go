@@ -0,0 +1,77 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/he-lium/sokoban"
+)
+
+// LevelDir creates sokoban.Board objects from the JSON level files of a
+// directory, taking each level in turn. Safe for use by concurrent games
+type LevelDir struct {
+	levels []JSONBoard
+	next   int
+	lock   sync.Mutex
+}
+
+// ensure sokoban.BoardMaker interface is implemented
+var _ sokoban.BoardMaker = (*LevelDir)(nil)
+
+// LoadLevelDir reads every .json file in dir, in order of file name
+func LoadLevelDir(dir string) (*LevelDir, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no .json levels in " + dir)
+	}
+	sort.Strings(files)
+
+	d := &LevelDir{levels: make([]JSONBoard, len(files))}
+	for i, f := range files {
+		d.levels[i].JSONContent, err = ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// Len returns the number of levels in the directory
+func (d *LevelDir) Len() int {
+	return len(d.levels)
+}
+
+// Band returns the levels of the directory with between minBoxes and maxBoxes
+// boxes inclusive, e.g. to make a difficulty band of levels
+func (d *LevelDir) Band(minBoxes, maxBoxes int) (*LevelDir, error) {
+	band := &LevelDir{}
+	for _, level := range d.levels {
+		b, err := level.GenBoard()
+		if err != nil {
+			return nil, err
+		}
+		if n := b.Stats().Targets; n >= minBoxes && n <= maxBoxes {
+			band.levels = append(band.levels, level)
+		}
+	}
+	if len(band.levels) == 0 {
+		return nil, fmt.Errorf("no levels with %d to %d boxes", minBoxes, maxBoxes)
+	}
+	return band, nil
+}
+
+// GenBoard generates the initial board of the next level
+func (d *LevelDir) GenBoard() (*sokoban.Board, error) {
+	d.lock.Lock()
+	gen := &d.levels[d.next]
+	d.next = (d.next + 1) % len(d.levels)
+	d.lock.Unlock()
+	return gen.GenBoard()
+}
@@ -0,0 +1,61 @@
+package parse_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/mock"
+	"github.com/he-lium/sokoban/parse"
+)
+
+func TestLevelDir(t *testing.T) {
+	dir := t.TempDir()
+	makers := []sokoban.BoardMaker{mock.BoardMaker1{}, mock.BoardMaker2{}, mock.BoardMaker3{}}
+	for i, m := range makers {
+		b, _ := m.GenBoard()
+		json, err := parse.BoardToJSON(b)
+		if err != nil {
+			t.Fatalf("error writing to JSON: %s", err.Error())
+		}
+		name := filepath.Join(dir, string(rune('a'+i))+".json")
+		if err := ioutil.WriteFile(name, json, 0644); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	d, err := parse.LoadLevelDir(dir)
+	if err != nil {
+		t.Fatalf("error loading levels: %s", err.Error())
+	}
+	if d.Len() != len(makers) {
+		t.Fatalf("loaded %d levels, expected %d", d.Len(), len(makers))
+	}
+	// levels are taken in turn, wrapping around
+	for _, id := range []int{0, 1, 2, 0} {
+		b, err := d.GenBoard()
+		if err != nil {
+			t.Fatalf("error generating board: %s", err.Error())
+		}
+		if b.ID != id {
+			t.Errorf("board has ID %d, expected %d", b.ID, id)
+		}
+	}
+
+	// only the third level has a box
+	band, err := d.Band(1, 3)
+	if err != nil {
+		t.Fatalf("error making band: %s", err.Error())
+	}
+	if b, _ := band.GenBoard(); band.Len() != 1 || b.ID != 2 {
+		t.Errorf("band has %d levels starting with %d, expected only 2", band.Len(), b.ID)
+	}
+	if _, err := d.Band(2, 3); err == nil {
+		t.Error("band without levels should fail")
+	}
+
+	if _, err := parse.LoadLevelDir(t.TempDir()); err == nil {
+		t.Error("loading empty directory should fail")
+	}
+}
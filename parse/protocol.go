@@ -0,0 +1,506 @@
+package parse
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/he-lium/sokoban"
+)
+
+// This file defines the messages of the websocket protocol. Every message is a
+// JSON object whose "type" field names one of the message types below. The
+// server greets each connection with a Hello carrying ProtocolVersion, and a
+// client may reply with its own Hello; clients speaking an unsupported version
+// have the rest of their input refused. Clients which don't say hello are
+// assumed to speak ProtocolVersion
+
+// Versions of the protocol understood by the server
+const (
+	ProtocolVersion    = 1 // version spoken by the server
+	MinProtocolVersion = 1 // oldest version accepted from clients
+)
+
+// Header holds the type discriminator common to every message
+type Header struct {
+	Type string `json:"type"`
+}
+
+// MessageType returns the type of the message, e.g. "move"
+func (h Header) MessageType() string {
+	return h.Type
+}
+
+// Message is implemented by every message of the protocol
+type Message interface {
+	MessageType() string
+}
+
+// ActionMessage is implemented by client messages which are actions in a game
+type ActionMessage interface {
+	Message
+	Action() sokoban.Action
+}
+
+// Hello announces the version of the protocol spoken by its sender. Sent by
+// the server when a client connects and by the client in reply
+type Hello struct {
+	Header
+	Version    int `json:"version"`
+	MinVersion int `json:"min_version,omitempty"` // oldest version the server accepts
+}
+
+// client-to-server messages
+
+// Move moves the player's worker in a direction: up, down, left or right
+type Move struct {
+	Header
+	Direction string `json:"direction"`
+}
+
+// Action converts the message to a sokoban.Action
+func (m Move) Action() sokoban.Action {
+	return sokoban.Action{Type: sokoban.Move, Direction: directions[m.Direction]}
+}
+
+// Undo undoes the player's last move
+type Undo struct {
+	Header
+}
+
+// Action converts the message to a sokoban.Action
+func (Undo) Action() sokoban.Action {
+	return sokoban.Action{Type: sokoban.Undo}
+}
+
+// Reset resets the player's board to the start
+type Reset struct {
+	Header
+}
+
+// Action converts the message to a sokoban.Action
+func (Reset) Action() sokoban.Action {
+	return sokoban.Action{Type: sokoban.Reset}
+}
+
+// StateRequest asks for the full state of the game after missing messages
+type StateRequest struct {
+	Header
+}
+
+// Join identifies the sender by a nickname, which players are rated and
+// recorded under. The first player to join with a nickname is sent a token in
+// Joined, which must be given to join with the nickname again. Players who
+// don't join aren't rated
+type Join struct {
+	Header
+	Name  string `json:"name"`            // at most MaxNameLength characters
+	Token string `json:"token,omitempty"` // token issued for the nickname, if any
+}
+
+// JoinQueue moves the sender to the public queue of the given name
+type JoinQueue struct {
+	Header
+	Queue string `json:"queue"`
+}
+
+// RaceGhost starts a single-player game against a ghost replaying the winner
+// of the recorded game with the given replay ID, on the same level. If Solver
+// is set, the ghost instead plays the server's solution of a level of the
+// sender's public queue, and Replay is ignored
+type RaceGhost struct {
+	Header
+	Replay string `json:"replay"`
+	Solver bool   `json:"solver,omitempty"`
+}
+
+// CreateRoom creates a private room with the sender as host. The game starts
+// once MaxPlayers have joined, or once MinPlayers have joined and the lobby
+// wait has run out. Zero counts take the server's defaults
+type CreateRoom struct {
+	Header
+	MinPlayers int `json:"min_players,omitempty"`
+	MaxPlayers int `json:"max_players,omitempty"`
+}
+
+// JoinRoom joins the private room with the given join code
+type JoinRoom struct {
+	Header
+	Code string `json:"code"`
+}
+
+// LeaveRoom leaves the private room, returning to the public queue
+type LeaveRoom struct {
+	Header
+}
+
+// Kick removes a player from the private room. Only the host may kick
+type Kick struct {
+	Header
+	Player int `json:"player"` // index of player in room
+}
+
+// StartRoom starts the game of a private room. Only the host may start
+type StartRoom struct {
+	Header
+}
+
+// Ready tells the server the player is ready for their matched game to start
+type Ready struct {
+	Header
+}
+
+// Chat sends a line of text to everyone in the same game or waiting room
+type Chat struct {
+	Header
+	Text string `json:"text"` // at most MaxChatLength characters
+}
+
+// Emote sends one of Emotes to everyone in the same game or waiting room
+type Emote struct {
+	Header
+	Emote string `json:"emote"`
+}
+
+// MaxChatLength is the most characters allowed in a chat message. The longest
+// chat fits in the server's 512 byte read limit even with every character
+// escaped as a surrogate pair, i.e. 12 bytes as in "\ud83d\ude00"
+const MaxChatLength = 40
+
+// MaxNameLength is the most characters allowed in a nickname
+const MaxNameLength = 20
+
+// Emotes are the emotes players may send
+var Emotes = []string{"wave", "gg", "laugh", "wow", "thumbs_up", "sad"}
+
+// clientMessages maps the type of each client-to-server message to its struct
+var clientMessages = []struct {
+	name string
+	typ  reflect.Type
+}{
+	{"hello", reflect.TypeOf(Hello{})},
+	{"move", reflect.TypeOf(Move{})},
+	{"undo", reflect.TypeOf(Undo{})},
+	{"reset", reflect.TypeOf(Reset{})},
+	{"state", reflect.TypeOf(StateRequest{})},
+	{"join", reflect.TypeOf(Join{})},
+	{"join_queue", reflect.TypeOf(JoinQueue{})},
+	{"race_ghost", reflect.TypeOf(RaceGhost{})},
+	{"create_room", reflect.TypeOf(CreateRoom{})},
+	{"join_room", reflect.TypeOf(JoinRoom{})},
+	{"leave_room", reflect.TypeOf(LeaveRoom{})},
+	{"kick", reflect.TypeOf(Kick{})},
+	{"start", reflect.TypeOf(StartRoom{})},
+	{"ready", reflect.TypeOf(Ready{})},
+	{"chat", reflect.TypeOf(Chat{})},
+	{"emote", reflect.TypeOf(Emote{})},
+}
+
+var directions = map[string]sokoban.Direction{
+	"up":    sokoban.Up,
+	"down":  sokoban.Down,
+	"left":  sokoban.Left,
+	"right": sokoban.Right,
+}
+
+// DecodeError describes a client message which couldn't be decoded. Code is
+// one of the error codes sent to clients, e.g. BadJSON
+type DecodeError struct {
+	Code    string
+	Message string
+}
+
+func (e *DecodeError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// DecodeClientMessage parses a message sent by a client, returning a value of
+// one of the client message types such as Move, or a *DecodeError
+func DecodeClientMessage(data []byte) (Message, error) {
+	var h Header
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, &DecodeError{BadJSON, err.Error()}
+	}
+	if h.Type == "" {
+		return nil, &DecodeError{UnknownAction, "missing type field"}
+	}
+
+	for _, m := range clientMessages {
+		if m.name != h.Type {
+			continue
+		}
+		v := reflect.New(m.typ)
+		if err := json.Unmarshal(data, v.Interface()); err != nil {
+			return nil, &DecodeError{BadJSON, err.Error()}
+		}
+		msg := v.Elem().Interface().(Message)
+		if err := validate(msg); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+	return nil, &DecodeError{UnknownAction, "unknown message type " + strconv.Quote(h.Type)}
+}
+
+// validate checks the fields of a decoded client message
+func validate(msg Message) error {
+	switch m := msg.(type) {
+	case Move:
+		if _, ok := directions[m.Direction]; !ok {
+			return &DecodeError{BadDirection, "unknown direction " + strconv.Quote(m.Direction)}
+		}
+	case Chat:
+		n := utf8.RuneCountInString(m.Text)
+		if n == 0 || n > MaxChatLength {
+			return &DecodeError{BadChat,
+				"chat must be 1 to " + strconv.Itoa(MaxChatLength) + " characters"}
+		}
+	case Join:
+		n := utf8.RuneCountInString(m.Name)
+		if n == 0 || n > MaxNameLength || strings.TrimSpace(m.Name) != m.Name ||
+			strings.IndexFunc(m.Name, func(r rune) bool { return !unicode.IsPrint(r) }) >= 0 {
+			return &DecodeError{BadName, "nickname must be 1 to " + strconv.Itoa(MaxNameLength) +
+				" printable characters, not starting or ending with a space"}
+		}
+	case Emote:
+		for _, e := range Emotes {
+			if e == m.Emote {
+				return nil
+			}
+		}
+		return &DecodeError{BadChat, "unknown emote " + strconv.Quote(m.Emote)}
+	}
+	return nil
+}
+
+// server-to-client messages
+
+// Joined tells a player the nickname they joined with, and the token needed
+// to join with it again. The token is only sent when the nickname is first
+// claimed
+type Joined struct {
+	Header
+	Name  string `json:"name"`
+	Token string `json:"token,omitempty"`
+}
+
+// Queued tells a player which public queue they are waiting in, and the
+// rating used to match them with similar players
+type Queued struct {
+	Header
+	Queue  string `json:"queue"`
+	Rating int    `json:"rating"`
+}
+
+// Match tells a player they have been matched for a game, which starts once
+// every player has sent Ready. Sent again whenever a player becomes ready
+type Match struct {
+	Header
+	Me      int    `json:"me"`         // index of current player in match
+	Ready   []bool `json:"ready"`      // players who are ready
+	Timeout int64  `json:"timeout_ms"` // time left to be ready
+}
+
+// Countdown counts down the seconds until a matched game starts
+type Countdown struct {
+	Header
+	Seconds int `json:"seconds"`
+}
+
+// Requeued tells a player they have been sent back to the public queue
+type Requeued struct {
+	Header
+	Reason string `json:"reason"`
+}
+
+// GameInit starts a game, giving the player their index and session token
+type GameInit struct {
+	Header
+	NPlayers  int      `json:"num_players"` // count of all players
+	Me        int      `json:"me"`          // index of current player
+	GameBoard board    `json:"board"`       // initial board
+	Token     string   `json:"token"`       // session token for reconnecting
+	Names     []string `json:"names"`       // nickname of each player, empty if not joined
+}
+
+// Resync carries the history of a game in progress. Sent with type "resync"
+// to a player who has reconnected, and "spectate" to a new spectator
+type Resync struct {
+	Header
+	NPlayers  int        `json:"num_players"`
+	Me        int        `json:"me"`      // -1 for spectators
+	GameBoard board      `json:"board"`   // initial board
+	History   [][]string `json:"history"` // directions moved by each player
+	Won       []bool     `json:"won"`     // players who have won
+}
+
+// State carries the full state of a game in progress, sent on request and at
+// intervals
+type State struct {
+	Resync
+	Boards []BoardState `json:"boards"` // current board of each player
+}
+
+// BoardState is the current state of a player's board
+type BoardState struct {
+	board
+	Stats BoardStats `json:"stats"`
+}
+
+// BoardStats summarises a player's progress
+type BoardStats struct {
+	Moves   int  `json:"moves"`
+	Pushes  int  `json:"pushes"`
+	Score   int  `json:"score"`
+	Targets int  `json:"targets"`
+	Won     bool `json:"won"`
+}
+
+// ActionResult tells a player whether their action succeeded
+type ActionResult struct {
+	Header
+	Player int  `json:"player"`
+	Valid  bool `json:"move_valid"`
+}
+
+// OpponentAction tells players of a successful action made by another player
+type OpponentAction struct {
+	Header
+	Player    int    `json:"player"`
+	Name      string `json:"name,omitempty"`      // nickname of the player, if joined
+	Action    string `json:"action"`              // move, undo or reset
+	Direction string `json:"direction,omitempty"` // only for moves
+}
+
+// Win announces that a player has finished their board
+type Win struct {
+	Header
+	Player int    `json:"player"`
+	Name   string `json:"name,omitempty"` // nickname of the player, if joined
+}
+
+// PersonalBest tells a player who has finished their board that they beat
+// their earlier solutions of the level in each of Records: "time", "moves" or
+// "pushes"
+type PersonalBest struct {
+	Header
+	Player  int      `json:"player"`
+	Records []string `json:"records"`
+}
+
+// Turn announces whose turn it is in a turn-based game
+type Turn struct {
+	Header
+	Player int `json:"player"`
+}
+
+// Limits tells a player their remaining time, moves and pushes
+type Limits struct {
+	Header
+	Player     int   `json:"player"`
+	TimeLeft   int64 `json:"time_left_ms"` // -1 if unlimited
+	MovesLeft  int   `json:"moves_left"`   // -1 if unlimited
+	PushesLeft int   `json:"pushes_left"`  // -1 if unlimited
+}
+
+// GameResult carries the final result of a game
+type GameResult struct {
+	Header
+	Order   []int          `json:"order"`   // players in order of finishing
+	Players []PlayerResult `json:"players"` // indexed by player number
+}
+
+// PlayerResult is the final result of one player
+type PlayerResult struct {
+	Player  int    `json:"player"`
+	Status  string `json:"status"`
+	Rank    int    `json:"rank"`
+	Moves   int    `json:"moves"`
+	Pushes  int    `json:"pushes"`
+	Elapsed int64  `json:"elapsed_ms"`
+}
+
+// Room describes a private room to one of its players
+type Room struct {
+	Header
+	Code       string       `json:"code"`    // join code of the room
+	Me         int          `json:"me"`      // index of current player in room
+	Players    []RoomPlayer `json:"players"` // in order of joining
+	MinPlayers int          `json:"min_players"`
+	MaxPlayers int          `json:"max_players"`
+}
+
+// RoomPlayer is a player waiting in a private room
+type RoomPlayer struct {
+	Player int    `json:"player"`
+	Name   string `json:"name,omitempty"` // nickname of the player, if joined
+	Host   bool   `json:"host"`
+}
+
+// Lobby lists the waiting rooms and games of a server
+type Lobby struct {
+	Header
+	Rooms []LobbyRoom `json:"rooms"`
+	Games []LobbyGame `json:"games"`
+}
+
+// ChatLine relays a chat message. Player is the sender's index in the game
+// or private room, or -1 in the public queue
+type ChatLine struct {
+	Header
+	Player int    `json:"player"`
+	Text   string `json:"text"`
+}
+
+// EmoteLine relays an emote. Player is as for ChatLine
+type EmoteLine struct {
+	Header
+	Player int    `json:"player"`
+	Emote  string `json:"emote"`
+}
+
+// Notice carries an announcement from the server's operators
+type Notice struct {
+	Header
+	Text string `json:"text"`
+}
+
+// ErrorMessage tells a client why their input was rejected
+type ErrorMessage struct {
+	Header
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// serverMessages maps the type of each server-to-client message to its struct
+var serverMessages = []struct {
+	name string
+	typ  reflect.Type
+}{
+	{"hello", reflect.TypeOf(Hello{})},
+	{"joined", reflect.TypeOf(Joined{})},
+	{"queued", reflect.TypeOf(Queued{})},
+	{"match", reflect.TypeOf(Match{})},
+	{"countdown", reflect.TypeOf(Countdown{})},
+	{"requeued", reflect.TypeOf(Requeued{})},
+	{"game_init", reflect.TypeOf(GameInit{})},
+	{"resync", reflect.TypeOf(Resync{})},
+	{"spectate", reflect.TypeOf(Resync{})},
+	{"state", reflect.TypeOf(State{})},
+	{"action_result", reflect.TypeOf(ActionResult{})},
+	{"opponent_action", reflect.TypeOf(OpponentAction{})},
+	{"win", reflect.TypeOf(Win{})},
+	{"personal_best", reflect.TypeOf(PersonalBest{})},
+	{"turn", reflect.TypeOf(Turn{})},
+	{"limits", reflect.TypeOf(Limits{})},
+	{"game_over", reflect.TypeOf(GameResult{})},
+	{"room", reflect.TypeOf(Room{})},
+	{"lobby", reflect.TypeOf(Lobby{})},
+	{"chat", reflect.TypeOf(ChatLine{})},
+	{"emote", reflect.TypeOf(EmoteLine{})},
+	{"notice", reflect.TypeOf(Notice{})},
+	{"error", reflect.TypeOf(ErrorMessage{})},
+}
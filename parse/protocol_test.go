@@ -0,0 +1,108 @@
+package parse_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/parse"
+)
+
+func TestDecodeClientMessage(t *testing.T) {
+	tables := []struct {
+		msg    string
+		action sokoban.Action
+		code   string // expected error code, empty if valid
+	}{
+		{`{"type":"move","direction":"left"}`, sokoban.Action{Type: sokoban.Move, Direction: sokoban.Left}, ""},
+		{`{"type":"undo"}`, sokoban.Action{Type: sokoban.Undo}, ""},
+		{`{"type":"reset"}`, sokoban.Action{Type: sokoban.Reset}, ""},
+		{`{"type":"move","direction":"sideways"}`, sokoban.Action{}, parse.BadDirection},
+		{`{"type":"fly"}`, sokoban.Action{}, parse.UnknownAction},
+		{`{"direction":"up"}`, sokoban.Action{}, parse.UnknownAction},
+		{`{"type":"kick","player":"one"}`, sokoban.Action{}, parse.BadJSON},
+		{`not json`, sokoban.Action{}, parse.BadJSON},
+		{`{"type":"chat","text":""}`, sokoban.Action{}, parse.BadChat},
+		{`{"type":"chat","text":"` + strings.Repeat("a", parse.MaxChatLength+1) + `"}`, sokoban.Action{}, parse.BadChat},
+		{`{"type":"emote","emote":"dance"}`, sokoban.Action{}, parse.BadChat},
+		{`{"type":"join","name":""}`, sokoban.Action{}, parse.BadName},
+		{`{"type":"join","name":" bob"}`, sokoban.Action{}, parse.BadName},
+		{`{"type":"join","name":"bob\u0007"}`, sokoban.Action{}, parse.BadName},
+		{`{"type":"join","name":"` + strings.Repeat("a", parse.MaxNameLength+1) + `"}`, sokoban.Action{}, parse.BadName},
+	}
+	for _, table := range tables {
+		msg, err := parse.DecodeClientMessage([]byte(table.msg))
+		if table.code != "" {
+			e, ok := err.(*parse.DecodeError)
+			if !ok || e.Code != table.code {
+				t.Errorf("decoding %s gave error %v, expected %s", table.msg, err, table.code)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("error decoding %s: %s", table.msg, err.Error())
+			continue
+		}
+		a, ok := msg.(parse.ActionMessage)
+		if !ok || a.Action() != table.action {
+			t.Errorf("decoded %s as %#v, expected action %v", table.msg, msg, table.action)
+		}
+	}
+
+	msg, err := parse.DecodeClientMessage([]byte(`{"type":"emote","emote":"gg"}`))
+	if emote, ok := msg.(parse.Emote); err != nil || !ok || emote.Emote != "gg" {
+		t.Errorf("decoded emote as %#v, %v", msg, err)
+	}
+
+	msg, err = parse.DecodeClientMessage([]byte(`{"type":"join_room","code":"AB2CD"}`))
+	if join, ok := msg.(parse.JoinRoom); err != nil || !ok || join.Code != "AB2CD" {
+		t.Errorf("decoded join_room as %#v, %v", msg, err)
+	}
+
+	msg, err = parse.DecodeClientMessage([]byte(`{"type":"join","name":"Ana María","token":"abc"}`))
+	if join, ok := msg.(parse.Join); err != nil || !ok || join.Name != "Ana María" || join.Token != "abc" {
+		t.Errorf("decoded join as %#v, %v", msg, err)
+	}
+}
+
+func TestProtocolSchema(t *testing.T) {
+	var schema struct {
+		Version     int `json:"version"`
+		Definitions map[string]struct {
+			Properties map[string]map[string]interface{} `json:"properties"`
+			Required   []string                          `json:"required"`
+		} `json:"definitions"`
+	}
+	if err := json.Unmarshal(parse.ProtocolSchema(), &schema); err != nil {
+		t.Fatalf("error parsing schema: %s", err.Error())
+	}
+	if schema.Version != parse.ProtocolVersion {
+		t.Errorf("schema version %d, expected %d", schema.Version, parse.ProtocolVersion)
+	}
+
+	move, ok := schema.Definitions["client.move"]
+	if !ok {
+		t.Fatal("schema has no client.move definition")
+	}
+	if move.Properties["type"]["const"] != "move" {
+		t.Errorf("client.move type is %v, expected const move", move.Properties["type"])
+	}
+	if move.Properties["direction"]["type"] != "string" {
+		t.Errorf("client.move direction is %v, expected string", move.Properties["direction"])
+	}
+
+	// fields of embedded structs are flattened, and omitempty fields optional
+	state := schema.Definitions["server.state"]
+	for _, field := range []string{"type", "seq", "history", "boards"} {
+		if state.Properties[field] == nil {
+			t.Errorf("server.state has no %s property", field)
+		}
+	}
+	opp := schema.Definitions["server.opponent_action"]
+	for _, field := range opp.Required {
+		if field == "direction" {
+			t.Error("server.opponent_action requires direction, expected optional")
+		}
+	}
+}
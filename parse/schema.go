@@ -0,0 +1,115 @@
+package parse
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ProtocolSchema generates a JSON Schema document describing every message
+// of the websocket protocol, for client authors. Client messages are defined
+// as "client.<type>" and server messages as "server.<type>"
+func ProtocolSchema() []byte {
+	defs := make(map[string]interface{})
+	client := make([]interface{}, 0, len(clientMessages))
+	for _, m := range clientMessages {
+		name := "client." + m.name
+		defs[name] = messageSchema(m.name, m.typ, false)
+		client = append(client, ref(name))
+	}
+	server := make([]interface{}, 0, len(serverMessages))
+	for _, m := range serverMessages {
+		name := "server." + m.name
+		defs[name] = messageSchema(m.name, m.typ, true)
+		server = append(server, ref(name))
+	}
+	defs["client"] = map[string]interface{}{"oneOf": client}
+	defs["server"] = map[string]interface{}{"oneOf": server}
+
+	schema := map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "sokoban websocket protocol",
+		"version":     ProtocolVersion,
+		"definitions": defs,
+		"oneOf":       []interface{}{ref("client"), ref("server")},
+	}
+	j, _ := json.MarshalIndent(schema, "", "  ")
+	return j
+}
+
+func ref(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/definitions/" + name}
+}
+
+// messageSchema describes a message of the given type. Messages from the
+// server are also numbered by a "seq" field
+func messageSchema(name string, t reflect.Type, fromServer bool) map[string]interface{} {
+	s := typeSchema(t)
+	props := s["properties"].(map[string]interface{})
+	props["type"] = map[string]interface{}{"const": name}
+	if fromServer {
+		props["seq"] = map[string]interface{}{"type": "integer", "minimum": 1}
+	}
+	return s
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// typeSchema describes the JSON encoding of values of type t
+func typeSchema(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case t.Kind() == reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case t.Kind() == reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case t.Kind() == reflect.Array:
+		return map[string]interface{}{
+			"type":     "array",
+			"items":    typeSchema(t.Elem()),
+			"minItems": t.Len(),
+			"maxItems": t.Len(),
+		}
+	case t.Kind() == reflect.Struct:
+		props := make(map[string]interface{})
+		required := make([]string, 0)
+		addFields(t, props, &required)
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": props,
+			"required":   required,
+		}
+	}
+	return map[string]interface{}{}
+}
+
+// addFields adds the JSON fields of struct type t, including those of
+// embedded structs, to props. Fields which are always present are required
+func addFields(t reflect.Type, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if f.Anonymous && tag == "" {
+			addFields(f.Type, props, required)
+			continue
+		}
+		if f.PkgPath != "" || tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = typeSchema(f.Type)
+		if !(len(parts) > 1 && parts[1] == "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -1,57 +1,286 @@
 package parse
 
 import (
+	"bytes"
 	"encoding/json"
+	"math"
+	"strconv"
+	"time"
 
 	"github.com/he-lium/sokoban"
 )
 
-// for generating JSON representation of server-to-client actions
+// for generating JSON representation of server-to-client messages, whose
+// types are defined in protocol.go
 
-// gameInit represents data to be initially sent to clients
-type gameInit struct {
-	NPlayers  int   `json:"num_players"` // count of all players
-	Me        int   `json:"me"`          // index of current player
-	GameBoard board `json:"board"`       // initial board
+// HelloJSON generates JSON greeting a client with the protocol version
+func HelloJSON() []byte {
+	j, _ := json.Marshal(Hello{Header{"hello"}, ProtocolVersion, MinProtocolVersion})
+	return j
+}
+
+// JoinedJSON generates JSON telling a player the nickname they joined with,
+// and its token if it was just issued
+func JoinedJSON(name string, token string) []byte {
+	j, _ := json.Marshal(Joined{Header{"joined"}, name, token})
+	return j
+}
+
+// QueuedJSON generates JSON telling a player the queue they are waiting in
+func QueuedJSON(queue string, rating float64) []byte {
+	j, _ := json.Marshal(Queued{Header{"queued"}, queue, int(math.Round(rating))})
+	return j
+}
+
+// MatchJSON generates JSON telling a player which players of their match are
+// ready, and the time left for the rest to be ready
+func MatchJSON(me int, ready []bool, timeout time.Duration) []byte {
+	j, _ := json.Marshal(Match{Header{"match"}, me, ready, int64(timeout / time.Millisecond)})
+	return j
 }
 
-// InitBoardJSON generates JSON file for initial state of the game
-func InitBoardJSON(nPlayers int, curr int, b *sokoban.Board) ([]byte, error) {
-	g := gameInit{nPlayers, curr, convertFromBoard(b)}
+// CountdownJSON generates JSON for the seconds left until a game starts
+func CountdownJSON(seconds int) []byte {
+	j, _ := json.Marshal(Countdown{Header{"countdown"}, seconds})
+	return j
+}
+
+// RequeuedJSON generates JSON telling a player why they are back in the
+// public queue
+func RequeuedJSON(reason string) []byte {
+	j, _ := json.Marshal(Requeued{Header{"requeued"}, reason})
+	return j
+}
+
+// InitBoardJSON generates JSON file for initial state of the game. names
+// holds the nickname of each player
+func InitBoardJSON(nPlayers int, curr int, b *sokoban.Board, token string, names []string) ([]byte, error) {
+	g := GameInit{Header{"game_init"}, nPlayers, curr, convertFromBoard(b), token, names}
 	return json.Marshal(g)
 }
 
-type actionResult struct {
-	Player int  `json:"player"`
-	Valid  bool `json:"move_valid"`
+// ResyncJSON generates JSON for the full state of a game in progress, sent to
+// a player who has reconnected. boards holds the current board of each player
+func ResyncJSON(me int, start *sokoban.Board, boards []*sokoban.Board) []byte {
+	j, _ := json.Marshal(newResync("resync", me, start, boards))
+	return j
 }
 
-// ActionResult generates JSON for the result of a player's action
-func ActionResult(player int, valid bool) []byte {
-	j, _ := json.Marshal(actionResult{player, valid})
+// StateJSON generates JSON for the full state of a game in progress: the
+// history of each player as sent by ResyncJSON, along with their current
+// board and its stats
+func StateJSON(me int, start *sokoban.Board, boards []*sokoban.Board) []byte {
+	st := State{newResync("state", me, start, boards), make([]BoardState, len(boards))}
+	for i, b := range boards {
+		st.Boards[i] = NewBoardState(b)
+	}
+	j, _ := json.Marshal(st)
 	return j
 }
 
-type opponentAction struct {
-	Player    int    `json:"player"`
-	Action    string `json:"action"`
-	Direction string `json:"direction"`
+// WithSeq adds a "seq" field holding the sequence number to a JSON object
+func WithSeq(msg []byte, seq uint64) []byte {
+	if len(msg) < 2 || msg[0] != '{' {
+		return msg
+	}
+	stamped := make([]byte, 0, len(msg)+24)
+	stamped = append(stamped, `{"seq":`...)
+	stamped = strconv.AppendUint(stamped, seq, 10)
+	if !bytes.Equal(bytes.TrimSpace(msg[1:]), []byte("}")) {
+		stamped = append(stamped, ',')
+	}
+	return append(stamped, msg[1:]...)
+}
+
+func newResync(typ string, me int, start *sokoban.Board, boards []*sokoban.Board) Resync {
+	r := Resync{
+		Header:    Header{typ},
+		NPlayers:  len(boards),
+		Me:        me,
+		GameBoard: convertFromBoard(start),
+		History:   make([][]string, len(boards)),
+		Won:       make([]bool, len(boards)),
+	}
+	for i, b := range boards {
+		h := b.History()
+		r.History[i] = make([]string, len(h))
+		for j, d := range h {
+			r.History[i][j] = sokoban.DirectionToStr(d)
+		}
+		r.Won[i] = b.Won()
+	}
+	return r
+}
+
+// ReconnectFailedJSON generates JSON telling a client that its session token
+// does not belong to a game in progress
+func ReconnectFailedJSON() []byte {
+	return ErrorJSON(ReconnectFailed, "game is over or token unknown")
+}
+
+// ActionResultJSON generates JSON for the result of a player's action
+func ActionResultJSON(player int, valid bool) []byte {
+	j, _ := json.Marshal(ActionResult{Header{"action_result"}, player, valid})
+	return j
 }
 
-// OpponentAction generates JSON for a move an opponent player has made
-func OpponentAction(player int, a sokoban.Action) []byte {
-	opp := opponentAction{
-		player,
-		sokoban.ActionTypeToStr(a.Type),
-		sokoban.DirectionToStr(a.Direction),
+// OpponentActionJSON generates JSON for a move an opponent player has made
+func OpponentActionJSON(player int, name string, a sokoban.Action) []byte {
+	opp := OpponentAction{
+		Header: Header{"opponent_action"},
+		Player: player,
+		Name:   name,
+		Action: sokoban.ActionTypeToStr(a.Type),
+	}
+	if a.Type == sokoban.Move {
+		opp.Direction = sokoban.DirectionToStr(a.Direction)
 	}
 	j, _ := json.Marshal(opp)
 	return j
 }
 
 // WinResultJSON generates JSON for a player win
-func WinResultJSON(player int) []byte {
-	a := opponentAction{player, "win", "?"}
-	j, _ := json.Marshal(a)
+func WinResultJSON(player int, name string) []byte {
+	j, _ := json.Marshal(Win{Header{"win"}, player, name})
+	return j
+}
+
+// PersonalBestJSON generates JSON telling a player which of their records
+// they have beaten
+func PersonalBestJSON(player int, records []string) []byte {
+	j, _ := json.Marshal(PersonalBest{Header{"personal_best"}, player, records})
+	return j
+}
+
+// GameOverJSON generates JSON for the final result of a game
+func GameOverJSON(r sokoban.Result) []byte {
+	g := GameResult{Header{"game_over"}, r.Order, make([]PlayerResult, len(r.Players))}
+	if g.Order == nil {
+		g.Order = make([]int, 0)
+	}
+	for i, p := range r.Players {
+		g.Players[i] = PlayerResult{
+			Player:  i,
+			Status:  sokoban.PlayerStatusToStr(p.Status),
+			Rank:    p.Rank,
+			Moves:   p.Moves,
+			Pushes:  p.Pushes,
+			Elapsed: int64(p.Elapsed / time.Millisecond),
+		}
+	}
+	j, _ := json.Marshal(g)
+	return j
+}
+
+// LimitsJSON generates JSON for a player's remaining time, moves and pushes
+func LimitsJSON(player int, s sokoban.LimitStatus) []byte {
+	l := Limits{Header{"limits"}, player, -1, s.MovesLeft, s.PushesLeft}
+	if s.TimeLeft >= 0 {
+		l.TimeLeft = int64(s.TimeLeft / time.Millisecond)
+	}
+	j, _ := json.Marshal(l)
+	return j
+}
+
+// TurnJSON generates JSON announcing whose turn it is in a turn-based game
+func TurnJSON(player int) []byte {
+	j, _ := json.Marshal(Turn{Header{"turn"}, player})
+	return j
+}
+
+// RoomJSON generates JSON describing a private room to one of its players,
+// given the nickname of each player, empty if not joined. Player 0 is the host
+func RoomJSON(code string, me int, names []string, minPlayers int, maxPlayers int) []byte {
+	r := Room{Header{"room"}, code, me, make([]RoomPlayer, len(names)), minPlayers, maxPlayers}
+	for i, name := range names {
+		r.Players[i] = RoomPlayer{i, name, i == 0}
+	}
+	j, _ := json.Marshal(r)
+	return j
+}
+
+// ChatJSON generates JSON relaying a chat message from a player
+func ChatJSON(player int, text string) []byte {
+	j, _ := json.Marshal(ChatLine{Header{"chat"}, player, text})
+	return j
+}
+
+// NoticeJSON generates JSON for a notice from the server's operators
+func NoticeJSON(text string) []byte {
+	j, _ := json.Marshal(Notice{Header{"notice"}, text})
+	return j
+}
+
+// EmoteJSON generates JSON relaying an emote from a player
+func EmoteJSON(player int, emote string) []byte {
+	j, _ := json.Marshal(EmoteLine{Header{"emote"}, player, emote})
+	return j
+}
+
+// Error codes sent to clients whose input was rejected
+const (
+	BadJSON            = "bad_json"            // message is not a JSON object
+	UnknownAction      = "unknown_action"      // missing or unrecognised message type
+	BadDirection       = "bad_direction"       // move without a valid direction
+	NotYourTurn        = "not_your_turn"       // action made out of turn
+	GameOver           = "game_over"           // player or game has finished
+	UnsupportedVersion = "unsupported_version" // protocol version not spoken
+	BadChat            = "bad_chat"            // chat too long or unknown emote
+	BadName            = "bad_name"            // nickname empty, too long or unprintable
+	NameTaken          = "name_taken"          // nickname in use or token doesn't match
+	RateLimited        = "rate_limited"        // chatting too quickly
+	RoomRefused        = "room_refused"        // room action not allowed
+	Kicked             = "kicked"              // removed from room by host
+	ReconnectFailed    = "reconnect_failed"    // session token not in a game
+	NoSuchGame         = "no_such_game"        // no game in progress with given id
+	NoSuchQueue        = "no_such_queue"       // no public queue with given name
+	NoSuchReplay       = "no_such_replay"      // no recorded game with given id, or no solution found
+)
+
+// ErrorJSON generates JSON telling a client why their input was rejected
+func ErrorJSON(code string, message string) []byte {
+	j, _ := json.Marshal(ErrorMessage{Header{"error"}, code, message})
+	return j
+}
+
+// LobbyRoom describes a room of players waiting for a game to start
+type LobbyRoom struct {
+	Name       string `json:"name"` // name of public queue, empty if private
+	Private    bool   `json:"private"`
+	Players    int    `json:"players"`
+	MinPlayers int    `json:"min_players"`
+	MaxPlayers int    `json:"max_players"`
+	Level      string `json:"level"`
+}
+
+// LobbyGame describes a game in progress
+type LobbyGame struct {
+	ID          int       `json:"id"`
+	Players     int       `json:"players"`
+	Playing     int       `json:"playing"` // players yet to finish
+	Level       string    `json:"level"`
+	BoardID     int       `json:"board_id"`
+	Started     time.Time `json:"started"`
+	Spectatable bool      `json:"spectatable"` // once the starting board is known
+}
+
+// LobbyJSON generates JSON listing the waiting rooms and games of a server
+func LobbyJSON(rooms []LobbyRoom, games []LobbyGame) []byte {
+	l := Lobby{Header{"lobby"}, rooms, games}
+	if l.Rooms == nil {
+		l.Rooms = make([]LobbyRoom, 0)
+	}
+	if l.Games == nil {
+		l.Games = make([]LobbyGame, 0)
+	}
+	j, _ := json.Marshal(l)
+	return j
+}
+
+// SpectateJSON generates JSON for the state of a game in progress, sent to a
+// spectator when they start watching. boards holds the current board of each
+// player
+func SpectateJSON(start *sokoban.Board, boards []*sokoban.Board) []byte {
+	j, _ := json.Marshal(newResync("spectate", -1, start, boards))
 	return j
 }
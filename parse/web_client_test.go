@@ -0,0 +1,74 @@
+package parse_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/mock"
+	"github.com/he-lium/sokoban/parse"
+)
+
+func TestWithSeq(t *testing.T) {
+	tables := []struct {
+		msg      string
+		expected string
+	}{
+		{`{"player":1}`, `{"seq":7,"player":1}`},
+		{`{}`, `{"seq":7}`},
+		{`not an object`, `not an object`},
+	}
+	for _, table := range tables {
+		got := string(parse.WithSeq([]byte(table.msg), 7))
+		if got != table.expected {
+			t.Errorf("WithSeq(%s) is %s, expected %s", table.msg, got, table.expected)
+		}
+	}
+}
+
+func TestStateJSON(t *testing.T) {
+	start, _ := mock.BoardMaker3{}.GenBoard()
+	b := start.Clone()
+	for _, d := range []sokoban.Direction{sokoban.Up, sokoban.Left} {
+		b.MakeMove(d)
+	}
+
+	var state struct {
+		History [][]string `json:"history"`
+		Boards  []struct {
+			Player [2]int `json:"player"`
+			Stats  struct {
+				Moves int `json:"moves"`
+			} `json:"stats"`
+		} `json:"boards"`
+	}
+	err := json.Unmarshal(parse.StateJSON(0, start, []*sokoban.Board{b, start}), &state)
+	if err != nil {
+		t.Fatalf("error parsing state: %s", err.Error())
+	}
+
+	if len(state.Boards) != 2 || len(state.History) != 2 {
+		t.Fatalf("state has %d boards and %d histories, expected 2",
+			len(state.Boards), len(state.History))
+	}
+	if state.Boards[0].Player != [2]int{3, 1} {
+		t.Errorf("player 0 at %v, expected [3 1]", state.Boards[0].Player)
+	}
+	if state.Boards[0].Stats.Moves != 2 || len(state.History[0]) != 2 {
+		t.Errorf("player 0 made %d moves with history %v, expected 2",
+			state.Boards[0].Stats.Moves, state.History[0])
+	}
+	if state.Boards[1].Stats.Moves != 0 {
+		t.Errorf("player 1 made %d moves, expected 0", state.Boards[1].Stats.Moves)
+	}
+}
+
+func TestTurnJSON(t *testing.T) {
+	var turn map[string]interface{}
+	if err := json.Unmarshal(parse.TurnJSON(1), &turn); err != nil {
+		t.Fatalf("error parsing turn: %s", err.Error())
+	}
+	if len(turn) != 2 || turn["type"] != "turn" || turn["player"] != 1.0 {
+		t.Errorf("turn is %v, expected only type turn and player 1", turn)
+	}
+}
@@ -0,0 +1,49 @@
+package sokoban
+
+import "math"
+
+// DefaultRating is the rating of a player who hasn't played a rated game
+const DefaultRating = 1500.0
+
+// RatingK is the most a player's rating can change in one game
+const RatingK = 32.0
+
+// Rate returns the ratings of the players of a game after its result r, given
+// their ratings before it. It uses the Elo system generalised to many players:
+// each pair of players is scored as a game between the two, won by the player
+// ranked higher, and drawn if neither finished. Changes are scaled down by the
+// number of opponents so a game moves a rating by at most RatingK
+func Rate(ratings []float64, r Result) []float64 {
+	n := len(ratings)
+	rated := make([]float64, n)
+	copy(rated, ratings)
+	if n < 2 {
+		return rated
+	}
+
+	k := RatingK / float64(n-1)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i == j {
+				continue
+			}
+			expected := 1 / (1 + math.Pow(10, (ratings[j]-ratings[i])/400))
+			rated[i] += k * (score(r.Players[i], r.Players[j]) - expected)
+		}
+	}
+	return rated
+}
+
+// score is the outcome of a game between a and b for a: 1 for a win, 0.5 for
+// a draw and 0 for a loss
+func score(a, b PlayerResult) float64 {
+	switch {
+	case a.Rank == b.Rank:
+		return 0.5
+	case a.Rank == 0:
+		return 0
+	case b.Rank == 0 || a.Rank < b.Rank:
+		return 1
+	}
+	return 0
+}
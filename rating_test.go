@@ -0,0 +1,48 @@
+package sokoban_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/he-lium/sokoban"
+)
+
+func TestRate(t *testing.T) {
+	// two equal players: the winner takes half of RatingK from the loser
+	r := sokoban.Result{Players: []sokoban.PlayerResult{
+		{Status: sokoban.Finished, Rank: 1},
+		{Status: sokoban.Unfinished},
+	}}
+	rated := sokoban.Rate([]float64{1500, 1500}, r)
+	if math.Abs(rated[0]-1516) > 1e-9 || math.Abs(rated[1]-1484) > 1e-9 {
+		t.Errorf("equal players rated %v, expected [1516 1484]", rated)
+	}
+
+	// neither finishing is a draw, which favours the weaker player
+	r = sokoban.Result{Players: []sokoban.PlayerResult{
+		{Status: sokoban.TimedOut},
+		{Status: sokoban.LeftGame},
+	}}
+	rated = sokoban.Rate([]float64{1700, 1300}, r)
+	if rated[0] >= 1700 || rated[1] <= 1300 {
+		t.Errorf("draw rated %v, expected stronger player to lose points", rated)
+	}
+
+	// ratings are conserved, and ordered by finishing position
+	r = sokoban.Result{Players: []sokoban.PlayerResult{
+		{Status: sokoban.Finished, Rank: 2},
+		{Status: sokoban.Unfinished},
+		{Status: sokoban.Finished, Rank: 1},
+	}}
+	before := []float64{1600, 1450, 1500}
+	rated = sokoban.Rate(before, r)
+	if math.Abs(rated[0]+rated[1]+rated[2]-4550) > 1e-9 {
+		t.Errorf("ratings %v don't sum to 4550", rated)
+	}
+	if !(rated[2] > before[2] && rated[1] < before[1]) {
+		t.Errorf("ratings %v after %v, expected winner up and last down", rated, before)
+	}
+	if before[0] != 1600 {
+		t.Error("Rate modified the ratings passed in")
+	}
+}
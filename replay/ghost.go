@@ -0,0 +1,107 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/he-lium/sokoban"
+)
+
+// A Ghost replays one player's actions in real time, so a solo player can
+// race against an earlier game or a solver's solution. The Controller
+// receives the ghost's actions as those of another player
+
+// Ghost is a timed sequence of actions to play against
+type Ghost struct {
+	steps []Step
+}
+
+// GhostFromReplay makes a Ghost of the successful actions of player in r. A
+// negative player takes the player who finished first, or player 0 if nobody
+// finished
+func GhostFromReplay(r *Replay, player int) (*Ghost, error) {
+	if player < 0 {
+		player = 0
+		if len(r.Order) > 0 {
+			player = r.Order[0]
+		}
+	}
+	if player >= r.NPlayers {
+		return nil, fmt.Errorf("replay: no player %d in a game of %d", player, r.NPlayers)
+	}
+	g := &Ghost{}
+	for _, s := range r.Steps {
+		if s.Player == player && s.Success && s.SokobanAction().Type != sokoban.Leave {
+			g.steps = append(g.steps, s)
+		}
+	}
+	return g, nil
+}
+
+// GhostFromSolution makes a Ghost moving once every interval through a
+// solution in LURD notation, as written by sokoban solvers: each of l, u, r
+// and d is a move left, up, right or down, in upper case if it pushes a box
+func GhostFromSolution(solution string, interval time.Duration) (*Ghost, error) {
+	var moves []sokoban.Direction
+	for _, ch := range solution {
+		switch ch {
+		case 'l', 'L':
+			moves = append(moves, sokoban.Left)
+		case 'u', 'U':
+			moves = append(moves, sokoban.Up)
+		case 'r', 'R':
+			moves = append(moves, sokoban.Right)
+		case 'd', 'D':
+			moves = append(moves, sokoban.Down)
+		case ' ', '\t', '\r', '\n':
+		default:
+			return nil, fmt.Errorf("replay: unexpected %q in solution", ch)
+		}
+	}
+	return GhostFromMoves(moves, interval)
+}
+
+// GhostFromMoves makes a Ghost making the given moves once every interval,
+// such as a solution found by sokoban.Solve
+func GhostFromMoves(moves []sokoban.Direction, interval time.Duration) (*Ghost, error) {
+	if len(moves) == 0 {
+		return nil, errors.New("replay: empty solution")
+	}
+	g := &Ghost{}
+	for i, d := range moves {
+		g.steps = append(g.steps, Step{
+			TimeMS:    int64(time.Duration(i+1) * interval / time.Millisecond),
+			Action:    sokoban.ActionTypeToStr(sokoban.Move),
+			Direction: sokoban.DirectionToStr(d),
+			Success:   true,
+		})
+	}
+	return g, nil
+}
+
+// Len returns the number of actions the ghost makes
+func (g *Ghost) Len() int {
+	return len(g.steps)
+}
+
+// Play calls act with each of the ghost's actions at the time it was made
+// after Play was called, then with a Leave action. Returns early once ctx is
+// done or act returns false
+func (g *Ghost) Play(ctx context.Context, act func(a sokoban.Action) bool) {
+	start := time.Now()
+	for _, s := range g.steps {
+		timer := time.NewTimer(time.Until(start.Add(s.Offset())))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+		if !act(s.SokobanAction()) {
+			return
+		}
+	}
+	act(sokoban.Action{Type: sokoban.Leave})
+}
@@ -0,0 +1,204 @@
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/parse"
+)
+
+// Replays record a game as its starting board followed by each player's
+// timestamped actions and whether they succeeded, so the game can be played
+// back by applying the actions to copies of the starting board
+
+// Version is the version of the replay format written by Recorder
+const Version = 1
+
+// Replay is the recording of a game
+type Replay struct {
+	Version    int             `json:"version"`
+	Started    time.Time       `json:"started"`
+	NPlayers   int             `json:"num_players"`
+	Board      json.RawMessage `json:"board"` // starting board as a JSON level
+	Steps      []Step          `json:"steps"`
+	Order      []int           `json:"order"` // players who finished, first to last
+	DurationMS int64           `json:"duration_ms"`
+}
+
+// Step is an action made by a player. Leaving the game is recorded as a
+// "leave" action
+type Step struct {
+	TimeMS    int64  `json:"t_ms"` // time since the game started
+	Player    int    `json:"player"`
+	Action    string `json:"action"`              // "move", "undo", "reset" or "leave"
+	Direction string `json:"direction,omitempty"` // direction of a move
+	Success   bool   `json:"success"`
+}
+
+// Offset returns the time since the game started at which the step was made
+func (s Step) Offset() time.Duration {
+	return time.Duration(s.TimeMS) * time.Millisecond
+}
+
+// SokobanAction converts the step to a sokoban.Action
+func (s Step) SokobanAction() sokoban.Action {
+	var a sokoban.Action
+	for _, t := range []sokoban.ActionType{sokoban.Move, sokoban.Undo, sokoban.Reset, sokoban.Leave} {
+		if sokoban.ActionTypeToStr(t) == s.Action {
+			a.Type = t
+		}
+	}
+	for _, d := range []sokoban.Direction{sokoban.Up, sokoban.Right, sokoban.Down, sokoban.Left} {
+		if sokoban.DirectionToStr(d) == s.Direction {
+			a.Direction = d
+		}
+	}
+	return a
+}
+
+// Duration returns the length of the game
+func (r *Replay) Duration() time.Duration {
+	return time.Duration(r.DurationMS) * time.Millisecond
+}
+
+// Load decodes a replay from JSON
+func Load(data []byte) (*Replay, error) {
+	var r Replay
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	if r.Version != Version {
+		return nil, errors.New("replay: unsupported version")
+	}
+	if r.NPlayers < 1 {
+		return nil, errors.New("replay: no players")
+	}
+	return &r, nil
+}
+
+// StartBoard generates the starting board of the game
+func (r *Replay) StartBoard() (*sokoban.Board, error) {
+	gen := &parse.JSONBoard{JSONContent: r.Board}
+	return gen.GenBoard()
+}
+
+// Recorder is a sokoban.Observer which records a game, saving the Replay to
+// a Store once the game ends
+type Recorder struct {
+	store  Store
+	replay *Replay
+}
+
+// ensure sokoban.Observer interface is implemented
+var _ sokoban.Observer = (*Recorder)(nil)
+
+// NewRecorder creates a Recorder saving to s
+func NewRecorder(s Store) *Recorder {
+	return &Recorder{store: s}
+}
+
+// Notify records the event
+func (r *Recorder) Notify(e sokoban.Event) {
+	switch e := e.(type) {
+	case sokoban.GameStarted:
+		board, err := parse.BoardToJSON(e.Board)
+		if err != nil {
+			log.Printf("replay: ERROR recording board: %s\n", err.Error())
+			return
+		}
+		r.replay = &Replay{
+			Version:  Version,
+			Started:  e.Time,
+			NPlayers: e.NPlayers,
+			Board:    board,
+			Steps:    make([]Step, 0),
+		}
+	case sokoban.ActionApplied:
+		if r.replay == nil {
+			return
+		}
+		s := r.step(e.Time, e.Player, e.Action.Type)
+		if e.Action.Type == sokoban.Move {
+			s.Direction = sokoban.DirectionToStr(e.Action.Direction)
+		}
+		s.Success = e.Success
+		r.replay.Steps = append(r.replay.Steps, s)
+	case sokoban.PlayerLeft:
+		if r.replay == nil {
+			return
+		}
+		s := r.step(e.Time, e.Player, sokoban.Leave)
+		s.Success = true
+		r.replay.Steps = append(r.replay.Steps, s)
+	case sokoban.GameEnded:
+		if r.replay == nil {
+			return
+		}
+		r.replay.Order = e.Result.Order
+		r.replay.DurationMS = int64(e.Result.Duration / time.Millisecond)
+		if id, err := r.store.Save(r.replay); err != nil {
+			log.Printf("replay: ERROR saving replay: %s\n", err.Error())
+		} else {
+			log.Printf("replay: saved %s\n", id)
+		}
+	}
+}
+
+func (r *Recorder) step(t time.Time, player int, a sokoban.ActionType) Step {
+	return Step{
+		TimeMS: int64(t.Sub(r.replay.Started) / time.Millisecond),
+		Player: player,
+		Action: sokoban.ActionTypeToStr(a),
+	}
+}
+
+// Playback applies the steps of a Replay in turn to each player's board
+type Playback struct {
+	replay *Replay
+	boards []*sokoban.Board
+	next   int
+}
+
+// NewPlayback starts playing back r from its starting board
+func NewPlayback(r *Replay) (*Playback, error) {
+	start, err := r.StartBoard()
+	if err != nil {
+		return nil, err
+	}
+	p := &Playback{replay: r, boards: make([]*sokoban.Board, r.NPlayers)}
+	for i := range p.boards {
+		p.boards[i] = start.Clone()
+	}
+	return p, nil
+}
+
+// Next applies the next step, returning false once every step is applied
+func (p *Playback) Next() (Step, bool) {
+	if p.next >= len(p.replay.Steps) {
+		return Step{}, false
+	}
+	s := p.replay.Steps[p.next]
+	p.next++
+	if !s.Success || s.Player < 0 || s.Player >= len(p.boards) {
+		return s, true
+	}
+	b := p.boards[s.Player]
+	a := s.SokobanAction()
+	switch a.Type {
+	case sokoban.Move:
+		b.MakeMove(a.Direction)
+	case sokoban.Undo:
+		b.UndoMove()
+	case sokoban.Reset:
+		b.Reset()
+	}
+	return s, true
+}
+
+// Board returns the current board of the player
+func (p *Playback) Board(player int) *sokoban.Board {
+	return p.boards[player]
+}
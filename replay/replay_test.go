@@ -0,0 +1,123 @@
+package replay_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/mock"
+	"github.com/he-lium/sokoban/replay"
+)
+
+func TestRecordAndPlayback(t *testing.T) {
+	store, err := replay.NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("error creating store: %s", err.Error())
+	}
+
+	// solve mock board 3, bumping into a wall on the way
+	c := mock.Controller{T: t}
+	c.Actions = []sokoban.Action{
+		{Type: sokoban.Move, Direction: sokoban.Up},
+		{Type: sokoban.Move, Direction: sokoban.Up},
+		{Type: sokoban.Move, Direction: sokoban.Left},
+		{Type: sokoban.Move, Direction: sokoban.Left},
+		{Type: sokoban.Move, Direction: sokoban.Down},
+		{Type: sokoban.Move, Direction: sokoban.Down},
+		{Type: sokoban.Move, Direction: sokoban.Right},
+	}
+	c.Results = []bool{true, false, true, true, true, true, true}
+	g, err := sokoban.InitGame(1, mock.BoardMaker3{}, &c)
+	if err != nil {
+		t.Fatalf("unable to init game: %s", err)
+	}
+	g.AddObserver(replay.NewRecorder(store))
+	g.Play()
+
+	infos, err := store.List()
+	if err != nil {
+		t.Fatalf("error listing replays: %s", err.Error())
+	}
+	if len(infos) != 1 || infos[0].Steps != len(c.Actions) || len(infos[0].Order) != 1 {
+		t.Fatalf("listed %v, expected one replay of %d steps", infos, len(c.Actions))
+	}
+	if _, err := store.Get("../" + infos[0].ID); err != replay.ErrNotFound {
+		t.Error("replay outside the store should not be found")
+	}
+
+	content, err := store.Get(infos[0].ID)
+	if err != nil {
+		t.Fatalf("error getting replay: %s", err.Error())
+	}
+	r, err := replay.Load(content)
+	if err != nil {
+		t.Fatalf("error loading replay: %s", err.Error())
+	}
+	p, err := replay.NewPlayback(r)
+	if err != nil {
+		t.Fatalf("error starting playback: %s", err.Error())
+	}
+	var steps int
+	for s, ok := p.Next(); ok; s, ok = p.Next() {
+		if s.SokobanAction() != c.Actions[steps] || s.Success != c.Results[steps] {
+			t.Errorf("step %d is %v, expected %v", steps, s, c.Actions[steps])
+		}
+		steps++
+	}
+	if b := p.Board(0); !b.Won() || b.MoveCount() != 6 {
+		t.Errorf("played back board won %t in %d moves, expected win in 6", b.Won(), b.MoveCount())
+	}
+}
+
+func TestGhost(t *testing.T) {
+	if _, err := replay.GhostFromSolution("ullxdR", time.Millisecond); err == nil {
+		t.Error("solution with unknown move should fail")
+	}
+	g, err := replay.GhostFromSolution("ull\nddR", time.Millisecond)
+	if err != nil {
+		t.Fatalf("error reading solution: %s", err.Error())
+	}
+
+	// the ghost solves mock board 3, then leaves
+	b, _ := mock.BoardMaker3{}.GenBoard()
+	var actions []sokoban.Action
+	g.Play(context.Background(), func(a sokoban.Action) bool {
+		actions = append(actions, a)
+		if a.Type == sokoban.Move && !b.MakeMove(a.Direction) {
+			t.Errorf("ghost move %d failed", len(actions))
+		}
+		return true
+	})
+	if len(actions) != g.Len()+1 || actions[len(actions)-1].Type != sokoban.Leave {
+		t.Errorf("ghost made actions %v, expected %d moves then leave", actions, g.Len())
+	}
+	if !b.Won() {
+		t.Error("ghost did not solve the board")
+	}
+
+	// ghosts of a replay take the winner's successful actions
+	r := &replay.Replay{NPlayers: 2, Order: []int{1}, Steps: []replay.Step{
+		{TimeMS: 10, Player: 0, Action: "move", Direction: "up", Success: true},
+		{TimeMS: 20, Player: 1, Action: "move", Direction: "up", Success: false},
+		{TimeMS: 30, Player: 1, Action: "undo", Success: true},
+	}}
+	if g, err := replay.GhostFromReplay(r, -1); err != nil {
+		t.Errorf("error making ghost of winner: %s", err.Error())
+	} else if g.Len() != 1 {
+		t.Errorf("ghost of winner has %d actions, expected 1", g.Len())
+	}
+	if _, err := replay.GhostFromReplay(r, 2); err == nil {
+		t.Error("ghost of missing player should fail")
+	}
+
+	// stops when act returns false
+	var n int
+	g.Play(context.Background(), func(a sokoban.Action) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("ghost made %d actions after being stopped", n)
+	}
+}
@@ -0,0 +1,107 @@
+package replay
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Info summarises a saved Replay for listing
+type Info struct {
+	ID         string    `json:"id"`
+	Started    time.Time `json:"started"`
+	NPlayers   int       `json:"num_players"`
+	Steps      int       `json:"steps"`
+	Order      []int     `json:"order"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// ErrNotFound is returned by Get when there is no replay with the given ID
+var ErrNotFound = errors.New("replay: not found")
+
+// Store saves Replays. Implementations must be safe for use by concurrent
+// games
+type Store interface {
+	Save(r *Replay) (id string, err error)
+	List() ([]Info, error)         // newest first
+	Get(id string) ([]byte, error) // JSON of the replay
+}
+
+// DirStore is a Store keeping each Replay as a JSON file in a local directory
+type DirStore struct {
+	dir string
+}
+
+// ensure Store interface is implemented
+var _ Store = (*DirStore)(nil)
+
+// NewDirStore creates a DirStore in dir, creating the directory if needed
+func NewDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DirStore{dir}, nil
+}
+
+// Save writes r to a new file named by its ID: the time the game started
+// followed by a random suffix
+func (s *DirStore) Save(r *Replay) (string, error) {
+	content, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	id := r.Started.UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+	return id, ioutil.WriteFile(filepath.Join(s.dir, id+".json"), content, 0644)
+}
+
+// List summarises every replay in the directory, newest first
+func (s *DirStore) List() ([]Info, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]Info, 0, len(files))
+	for _, f := range files {
+		content, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		r, err := Load(content)
+		if err != nil {
+			continue // not a replay
+		}
+		infos = append(infos, Info{
+			ID:         strings.TrimSuffix(filepath.Base(f), ".json"),
+			Started:    r.Started,
+			NPlayers:   r.NPlayers,
+			Steps:      len(r.Steps),
+			Order:      r.Order,
+			DurationMS: r.DurationMS,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Started.After(infos[j].Started) })
+	return infos, nil
+}
+
+// Get reads the replay with the given ID
+func (s *DirStore) Get(id string) ([]byte, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return nil, ErrNotFound
+	}
+	content, err := ioutil.ReadFile(filepath.Join(s.dir, id+".json"))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return content, err
+}
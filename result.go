@@ -0,0 +1,59 @@
+package sokoban
+
+import "time"
+
+// Result summarises a finished Game, ranking the players who solved their
+// board by the order in which they finished
+type Result struct {
+	Order    []int          // players who finished, first to last
+	Players  []PlayerResult // indexed by player number
+	Duration time.Duration  // total length of the game
+}
+
+// PlayerResult holds the outcome and statistics of a single player
+type PlayerResult struct {
+	Status  PlayerStatus
+	Rank    int           // 1-based finishing position, 0 if not finished
+	Moves   int           // moves in the player's solution
+	Pushes  int           // box pushes in the player's solution
+	Elapsed time.Duration // time taken to finish or leave, else length of game
+}
+
+// PlayerStatus describes how a player's game ended
+type PlayerStatus int
+
+// Unfinished: player was still playing when the game ended
+// Finished: player solved the board
+// LeftGame: player left the game before solving the board
+// TimedOut: player ran out of time before solving the board
+// LimitReached: player used up their moves or pushes before solving the board
+const (
+	Unfinished   PlayerStatus = iota
+	Finished     PlayerStatus = iota
+	LeftGame     PlayerStatus = iota
+	TimedOut     PlayerStatus = iota
+	LimitReached PlayerStatus = iota
+)
+
+// PlayerStatusToStr gets the name string of a PlayerStatus
+func PlayerStatusToStr(s PlayerStatus) string {
+	var str = "?"
+	switch s {
+	case Unfinished:
+		str = "unfinished"
+	case Finished:
+		str = "finished"
+	case LeftGame:
+		str = "left"
+	case TimedOut:
+		str = "timed_out"
+	case LimitReached:
+		str = "limit_reached"
+	}
+	return str
+}
+
+// Done returns whether the player can no longer make any moves
+func (r PlayerResult) Done() bool {
+	return r.Status != Unfinished
+}
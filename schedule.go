@@ -0,0 +1,102 @@
+package sokoban
+
+import (
+	"errors"
+	"time"
+)
+
+// TurnMode selects how the players of a Game take turns
+type TurnMode int
+
+// RealTime: every player may act at any time
+// Alternate: players take turns making a single action each
+// MultiMove: players take turns making Schedule.MovesPerTurn actions each
+const (
+	RealTime  TurnMode = iota
+	Alternate TurnMode = iota
+	MultiMove TurnMode = iota
+)
+
+// Schedule determines the turn order of a Game. Turns pass between players
+// who haven't finished, in order of player number, and only successful
+// actions count towards a turn
+type Schedule struct {
+	Mode         TurnMode
+	MovesPerTurn int // used by MultiMove
+}
+
+// TurnNotifier is optionally implemented by Controllers of turn-based games
+// to tell players whose turn it is. NotifyTurn is called when the game starts
+// and whenever the turn passes to another player
+type TurnNotifier interface {
+	NotifyTurn(player int)
+}
+
+// ErrNotYourTurn is the reason an action made out of turn is rejected
+var ErrNotYourTurn = errors.New("sokoban: not the player's turn")
+
+// SetSchedule sets the turn order of the Game. Must be called before Play
+func (g *Game) SetSchedule(s Schedule) {
+	g.schedule = s
+}
+
+// movesPerTurn returns the number of actions a player makes each turn, or 0
+// for real-time games
+func (s Schedule) movesPerTurn() int {
+	switch s.Mode {
+	case Alternate:
+		return 1
+	case MultiMove:
+		if s.MovesPerTurn > 0 {
+			return s.MovesPerTurn
+		}
+		return 1
+	default:
+		return 0
+	}
+}
+
+// inTurn returns whether player p may act now
+func (g *Game) inTurn(p int) bool {
+	return g.schedule.movesPerTurn() == 0 || g.turn == p
+}
+
+// startTurns notifies players of the first turn of a turn-based game
+func (g *Game) startTurns() {
+	if g.schedule.movesPerTurn() == 0 {
+		return
+	}
+	g.turn = len(g.players) - 1
+	g.nextTurn()
+}
+
+// endAction updates the turn after player p has acted, passing the turn on
+// once p has used up their actions or is done
+func (g *Game) endAction(p int, success bool) {
+	perTurn := g.schedule.movesPerTurn()
+	if perTurn == 0 || p != g.turn {
+		return
+	}
+	if success {
+		g.turnMoves++
+	}
+	if g.turnMoves >= perTurn || g.players[p].Done() {
+		g.nextTurn()
+	}
+}
+
+// nextTurn passes the turn to the next player who isn't done
+func (g *Game) nextTurn() {
+	for i := 1; i <= len(g.players); i++ {
+		next := (g.turn + i) % len(g.players)
+		if !g.players[next].Done() {
+			g.turn = next
+			g.turnMoves = 0
+			g.notify(TurnStarted{time.Now(), next})
+			if n, ok := g.control.(TurnNotifier); ok {
+				n.NotifyTurn(next)
+			}
+			return
+		}
+	}
+}
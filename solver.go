@@ -0,0 +1,134 @@
+package sokoban
+
+import "sort"
+
+// Solve searches breadth first for the shortest sequence of moves winning b
+// from its current position, giving up after visiting maxStates positions.
+// Returns false if b has no solution or none was found in time
+func Solve(b *Board, maxStates int) ([]Direction, bool) {
+	if len(b.targets) == 0 {
+		return nil, false
+	}
+	dead := deadSquares(b)
+	start := solverNode{player: b.Player, boxes: sortedPoints(b.boxes), parent: -1}
+	if solvedBy(b, start.boxes) {
+		return []Direction{}, true
+	}
+
+	nodes := []solverNode{start}
+	seen := map[string]bool{start.key(b.Width): true}
+	for i := 0; i < len(nodes) && len(nodes) < maxStates; i++ {
+		n := nodes[i]
+		for _, d := range []Direction{Up, Right, Down, Left} {
+			dx, dy := directionDelta(d)
+			next := Point{n.player.X + dx, n.player.Y + dy}
+			if !b.validSpace(next) {
+				continue
+			}
+			boxes := n.boxes
+			if box := pointIndex(n.boxes, next); box >= 0 {
+				next2 := Point{next.X + dx, next.Y + dy}
+				if !b.validSpace(next2) || dead[next2] || pointIndex(n.boxes, next2) >= 0 {
+					continue
+				}
+				boxes = make([]Point, len(n.boxes))
+				copy(boxes, n.boxes)
+				boxes[box] = next2
+				boxes = sortedPoints(boxes)
+			}
+
+			child := solverNode{player: next, boxes: boxes, parent: i, dir: d}
+			key := child.key(b.Width)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			nodes = append(nodes, child)
+			if solvedBy(b, boxes) {
+				return solverPath(nodes, len(nodes)-1), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// a position reached by the solver, and the move from its parent
+type solverNode struct {
+	player Point
+	boxes  []Point // sorted so equal positions have equal keys
+	parent int
+	dir    Direction
+}
+
+// key identifies the node's position for detecting repeats
+func (n solverNode) key(width int) string {
+	k := make([]byte, 0, 2*(len(n.boxes)+1))
+	for _, p := range append([]Point{n.player}, n.boxes...) {
+		i := p.Y*width + p.X
+		k = append(k, byte(i>>8), byte(i))
+	}
+	return string(k)
+}
+
+// solverPath returns the moves leading from the first node to nodes[i]
+func solverPath(nodes []solverNode, i int) []Direction {
+	var path []Direction
+	for ; nodes[i].parent >= 0; i = nodes[i].parent {
+		path = append(path, nodes[i].dir)
+	}
+	for l, r := 0, len(path)-1; l < r; l, r = l+1, r-1 {
+		path[l], path[r] = path[r], path[l]
+	}
+	return path
+}
+
+// deadSquares returns the corners a box can never be pushed out of. A box in
+// one which isn't a target makes the board unsolvable
+func deadSquares(b *Board) map[Point]bool {
+	dead := make(map[Point]bool)
+	for x := 0; x < b.Width; x++ {
+		for y := 0; y < b.Height; y++ {
+			p := Point{x, y}
+			if !b.validSpace(p) || b.Grid[x][y].ItemType == Target {
+				continue
+			}
+			vertical := !b.validSpace(Point{x, y - 1}) || !b.validSpace(Point{x, y + 1})
+			horizontal := !b.validSpace(Point{x - 1, y}) || !b.validSpace(Point{x + 1, y})
+			if vertical && horizontal {
+				dead[p] = true
+			}
+		}
+	}
+	return dead
+}
+
+// solvedBy returns whether boxes cover every target of b
+func solvedBy(b *Board, boxes []Point) bool {
+	for _, t := range b.targets {
+		if pointIndex(boxes, t) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func pointIndex(points []Point, p Point) int {
+	for i, q := range points {
+		if q == p {
+			return i
+		}
+	}
+	return -1
+}
+
+func sortedPoints(points []Point) []Point {
+	sorted := make([]Point, len(points))
+	copy(sorted, points)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Y != sorted[j].Y {
+			return sorted[i].Y < sorted[j].Y
+		}
+		return sorted[i].X < sorted[j].X
+	})
+	return sorted
+}
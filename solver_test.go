@@ -0,0 +1,56 @@
+package sokoban_test
+
+import (
+	"testing"
+
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/mock"
+)
+
+func TestSolve(t *testing.T) {
+	b, err := mock.BoardMaker3{}.GenBoard()
+	if err != nil {
+		t.Fatalf("unable to create board: %s", err)
+	}
+	path, ok := sokoban.Solve(b, 1000)
+	if !ok || len(path) != 6 {
+		t.Fatalf("solved board 3 in %v (%t), expected 6 moves", path, ok)
+	}
+	for i, d := range path {
+		if !b.MakeMove(d) {
+			t.Fatalf("move %d of solution %v failed", i, path)
+		}
+	}
+	if !b.Won() {
+		t.Errorf("solution %v did not win", path)
+	}
+
+	// already won, and solving from part way through
+	if path, ok := sokoban.Solve(b, 1000); !ok || len(path) != 0 {
+		t.Errorf("solved won board in %v (%t), expected no moves", path, ok)
+	}
+	b.UndoMove()
+	b.UndoMove()
+	if path, ok := sokoban.Solve(b, 1000); !ok || len(path) != 2 {
+		t.Errorf("solved board in %v (%t), expected 2 moves", path, ok)
+	}
+
+	// gives up once out of states
+	b.Reset()
+	if _, ok := sokoban.Solve(b, 5); ok {
+		t.Error("solver should give up after 5 states")
+	}
+
+	// boards without boxes or targets can't be won
+	if _, ok := sokoban.Solve(mustGen(t, mock.BoardMaker2{}), 1000); ok {
+		t.Error("board without targets should not be solved")
+	}
+}
+
+func mustGen(t *testing.T, m sokoban.BoardMaker) *sokoban.Board {
+	b, err := m.GenBoard()
+	if err != nil {
+		t.Fatalf("unable to create board: %s", err)
+	}
+	return b
+}
@@ -1,15 +1,23 @@
 package terminal
 
 import (
-	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/leaderboard"
+	"github.com/he-lium/sokoban/replay"
 )
 
 // Controller implements sokoban.Controller and interacts with the user via
-// command line, printing the board and accepting keystrokes as moves
+// command line, printing the board and accepting keystrokes as moves.
+// Games with more than one player should use a turn-based sokoban.Schedule,
+// which decides whose keystrokes are read, unless the last player is a Ghost.
+// A Ghost races the user in real time, shown beside the user's board
 type Controller struct {
 	R          io.Reader
 	W          io.Writer
@@ -18,32 +26,68 @@ type Controller struct {
 	won        []bool
 	NPlayers   int
 	currPlayer int
-	reader     *bufio.Reader
+	input      *input // keystrokes read from R
+	best       string // personal best to print once the win is shown
+
+	Ghost      *replay.Ghost       // plays as the last player, if not nil
+	ghostMoves chan sokoban.Action // actions of the ghost as they are due
+	stopGhost  context.CancelFunc
+	boards     []*sokoban.Board // current board of each player in a ghost race
+	prompted   bool             // actions were listed since the user's last action
 }
 
 var _ sokoban.Controller = (*Controller)(nil)
+var _ sokoban.LimitReporter = (*Controller)(nil)
+var _ sokoban.TurnNotifier = (*Controller)(nil)
+var _ leaderboard.BestNotifier = (*Controller)(nil)
 
 // Init prints the initial state of the board to the user
 func (c *Controller) Init(b *sokoban.Board) {
 	fmt.Fprintln(c.W, "Welcome to 倉庫番!")
-	c.reader = bufio.NewReader(c.R)
-	showBoard(c.W, b)
-
+	c.input = readInput(c.R)
 	c.won = make([]bool, c.NPlayers)
+
+	if c.Ghost == nil {
+		showBoard(c.W, b)
+		return
+	}
+	c.boards = make([]*sokoban.Board, c.NPlayers)
+	for i := range c.boards {
+		c.boards[i] = b
+	}
+	showBoards(c.W, c.boards)
+
+	var ctx context.Context
+	ctx, c.stopGhost = context.WithCancel(context.Background())
+	c.ghostMoves = make(chan sokoban.Action)
+	go c.Ghost.Play(ctx, func(a sokoban.Action) bool {
+		select {
+		case c.ghostMoves <- a:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
 }
 
-// RecvInput asks the user for an action
-func (c *Controller) RecvInput() (int, sokoban.Action) {
+// RecvInput asks the user for an action, returning early if ctx is done or
+// input has been exhausted. In a ghost race, the ghost's actions are returned
+// as they are due
+func (c *Controller) RecvInput(ctx context.Context) (int, sokoban.Action, error) {
 	var a sokoban.Action
 
-	for c.won[c.currPlayer] {
-		c.currPlayer = (c.currPlayer + 1) % c.NPlayers
-	}
-	if c.NPlayers > 1 {
+	if c.NPlayers > 1 && c.Ghost == nil {
 		fmt.Fprintf(c.W, "Player %d: ", c.currPlayer+1)
 	}
 
-	switch c.prompt() {
+	r, ghost, err := c.prompt(ctx)
+	if err != nil {
+		return c.currPlayer, a, err
+	} else if ghost != nil {
+		return c.NPlayers - 1, *ghost, nil
+	}
+
+	switch r {
 	case 'w':
 		a = sokoban.Action{Type: sokoban.Move, Direction: sokoban.Up}
 	case 'a':
@@ -57,45 +101,160 @@ func (c *Controller) RecvInput() (int, sokoban.Action) {
 	case 'r':
 		a.Type = sokoban.Reset
 	}
-	return c.currPlayer, a
+	return c.currPlayer, a, nil
 }
 
-func (c *Controller) prompt() rune {
-	fmt.Fprintln(c.W, `Select Actions:
+// prompt lists the actions, unless listed since the user's last action in a
+// ghost race, and waits for a keystroke or the ghost's next action
+func (c *Controller) prompt(ctx context.Context) (rune, *sokoban.Action, error) {
+	if !c.prompted {
+		fmt.Fprintln(c.W, `Select Actions:
 (w) Up   (a) Left   (s) Down   (d) Right   (u) Undo   (r) Restart`)
-	r, _, err := c.reader.ReadRune()
-	for err != nil || r == '\n' {
-		fmt.Fprint(c.W, "> ")
-		r, _, err = c.reader.ReadRune()
+		c.prompted = c.Ghost != nil
+	}
+	for {
+		select {
+		case r, ok := <-c.input.keys:
+			if !ok {
+				return 0, nil, c.input.err
+			}
+			if r != '\n' {
+				c.prompted = false
+				return r, nil, nil
+			}
+			fmt.Fprint(c.W, "> ")
+		case a := <-c.ghostMoves:
+			return 0, &a, nil
+		case <-ctx.Done():
+			fmt.Fprintln(c.W)
+			return 0, nil, ctx.Err()
+		}
 	}
-	return r
 }
 
 // SendResult shows whether the user's action was successful
 func (c *Controller) SendResult(p int, success bool, a sokoban.Action) {
-	if !success {
+	if !success && !c.isGhost(p) {
 		fmt.Fprintln(c.W, "Invalid action")
-	} else {
-		c.currPlayer = (c.currPlayer + 1) % c.NPlayers
 	}
 	c.valid = success
 }
 
 // OutputBoard prints the current board
 func (c *Controller) OutputBoard(p int, b *sokoban.Board) {
-	if c.valid {
+	if !c.valid {
+		return
+	}
+	if c.Ghost != nil {
+		c.boards[p] = b
+		showBoards(c.W, c.boards)
+	} else {
 		showBoard(c.W, b)
-		if b.Won() && !c.won[p] {
-			fmt.Fprintln(c.W, "You win!")
-			c.won[p] = true
-			c.nWon++
+	}
+	if b.Won() && !c.won[p] {
+		c.won[p] = true
+		if c.isGhost(p) {
+			fmt.Fprintln(c.W, "The ghost wins!")
+			return
+		}
+		fmt.Fprintln(c.W, "You win!")
+		fmt.Fprint(c.W, c.best)
+		c.best = ""
+		c.nWon++
+	}
+}
+
+// NotifyTurn reads the following keystrokes as the given player's actions
+func (c *Controller) NotifyTurn(p int) {
+	c.currPlayer = p
+}
+
+// NotifyBest prints which of the player's records they have beaten, after
+// their win is shown
+func (c *Controller) NotifyBest(p int, best []leaderboard.Ranking) {
+	records := make([]string, len(best))
+	for i, by := range best {
+		switch by {
+		case leaderboard.ByTime:
+			records[i] = "fastest time"
+		case leaderboard.ByMoves:
+			records[i] = "fewest moves"
+		case leaderboard.ByPushes:
+			records[i] = "fewest pushes"
 		}
 	}
+	c.best = fmt.Sprintf("New personal best! (%s)\n", strings.Join(records, ", "))
 }
 
-// Closing signals whether the game has been won
+// ReportLimits prints the player's remaining time, moves and pushes
+func (c *Controller) ReportLimits(p int, s sokoban.LimitStatus) {
+	if c.NPlayers > 1 {
+		fmt.Fprintf(c.W, "Player %d: ", p+1)
+	}
+	if s.TimeLeft >= 0 {
+		fmt.Fprintf(c.W, "Time left: %v   ", s.TimeLeft.Round(time.Second))
+	}
+	if s.MovesLeft >= 0 {
+		fmt.Fprintf(c.W, "Moves left: %d   ", s.MovesLeft)
+	}
+	if s.PushesLeft >= 0 {
+		fmt.Fprintf(c.W, "Pushes left: %d", s.PushesLeft)
+	}
+	fmt.Fprintln(c.W)
+}
+
+// Closing signals whether the game has been won by every player. A ghost
+// doesn't need to finish
 func (c *Controller) Closing() bool {
-	return c.nWon == c.NPlayers
+	players := c.NPlayers
+	if c.Ghost != nil {
+		players--
+	}
+	if c.nWon < players {
+		return false
+	}
+	if c.stopGhost != nil {
+		c.stopGhost()
+	}
+	return true
+}
+
+// isGhost determines whether player p is the ghost
+func (c *Controller) isGhost(p int) bool {
+	return c.Ghost != nil && p == c.NPlayers-1
+}
+
+// showBoards prints the boards of a ghost race side by side
+func showBoards(w io.Writer, boards []*sokoban.Board) {
+	var columns [][]string
+	width := 0
+	for i, b := range boards {
+		var buf bytes.Buffer
+		if i == len(boards)-1 {
+			fmt.Fprintln(&buf, "Ghost")
+		} else {
+			fmt.Fprintln(&buf, "You")
+		}
+		showBoard(&buf, b)
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		columns = append(columns, lines)
+		if len(lines) > width {
+			width = len(lines)
+		}
+	}
+	for row := 0; row < width; row++ {
+		for i, lines := range columns {
+			var line string
+			if row < len(lines) {
+				line = lines[row]
+			}
+			if i < len(columns)-1 {
+				line = fmt.Sprintf("%-*s", boards[i].Width+3, line)
+			}
+			fmt.Fprint(w, line)
+		}
+		fmt.Fprintln(w)
+	}
 }
 
 func showBoard(w io.Writer, b *sokoban.Board) {
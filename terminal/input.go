@@ -0,0 +1,30 @@
+package terminal
+
+import (
+	"bufio"
+	"io"
+)
+
+// input forwards the keystrokes read from an io.Reader in the background
+type input struct {
+	keys chan rune // closed once reading fails
+	err  error     // set before keys is closed
+}
+
+// readInput starts forwarding keystrokes from r until r fails
+func readInput(r io.Reader) *input {
+	in := &input{keys: make(chan rune)}
+	go func() {
+		br := bufio.NewReader(r)
+		for {
+			ch, _, err := br.ReadRune()
+			if err != nil {
+				in.err = err
+				close(in.keys)
+				return
+			}
+			in.keys <- ch
+		}
+	}()
+	return in
+}
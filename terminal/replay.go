@@ -0,0 +1,108 @@
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/replay"
+)
+
+// Viewer plays back a replay.Replay, printing each board as it changes with
+// the time between steps divided by Speed. While playing, the keystrokes
+// + and - double and halve the speed, and q stops the replay
+type Viewer struct {
+	R     io.Reader
+	W     io.Writer
+	Speed float64
+}
+
+// Play prints each step of r in turn, returning early if ctx is done
+func (v *Viewer) Play(ctx context.Context, r *replay.Replay) error {
+	p, err := replay.NewPlayback(r)
+	if err != nil {
+		return err
+	}
+	if v.Speed <= 0 {
+		v.Speed = 1
+	}
+	input := readInput(v.R)
+
+	fmt.Fprintf(v.W, "Replay of %d player game started %s\n", r.NPlayers, r.Started.Format(time.RFC1123))
+	fmt.Fprintln(v.W, "(+) Faster   (-) Slower   (q) Quit")
+	start, err := r.StartBoard()
+	if err != nil {
+		return err
+	}
+	showBoard(v.W, start)
+
+	var last time.Duration
+	for s, ok := p.Next(); ok; s, ok = p.Next() {
+		wait := time.Duration(float64(s.Offset()-last) / v.Speed)
+		last = s.Offset()
+		if quit, err := v.wait(ctx, wait, input.keys); quit || err != nil {
+			return err
+		}
+
+		if r.NPlayers > 1 {
+			fmt.Fprintf(v.W, "Player %d: ", s.Player+1)
+		}
+		fmt.Fprintf(v.W, "[%v] %s", s.Offset().Round(time.Second/10), s.Action)
+		if s.Direction != "" {
+			fmt.Fprint(v.W, " "+s.Direction)
+		}
+		if !s.Success {
+			fmt.Fprint(v.W, " (invalid)")
+		}
+		fmt.Fprintln(v.W)
+		if s.Success && s.SokobanAction().Type != sokoban.Leave {
+			showBoard(v.W, p.Board(s.Player))
+		}
+	}
+
+	for i, player := range r.Order {
+		fmt.Fprintf(v.W, "%d. Player %d\n", i+1, player+1)
+	}
+	fmt.Fprintf(v.W, "Game over after %v\n", r.Duration())
+	return nil
+}
+
+// wait sleeps for d, adjusting the speed and the time left as + and - are
+// pressed. Returns true once q is pressed
+func (v *Viewer) wait(ctx context.Context, d time.Duration, input <-chan rune) (bool, error) {
+	timer := time.NewTimer(d)
+	defer func() { timer.Stop() }()
+	started := time.Now()
+	for {
+		select {
+		case <-timer.C:
+			return false, nil
+		case r, ok := <-input:
+			if !ok {
+				input = nil // input exhausted; keep playing
+				continue
+			}
+			var factor float64
+			switch r {
+			case '+':
+				factor = 2
+			case '-':
+				factor = 0.5
+			case 'q':
+				return true, nil
+			default:
+				continue
+			}
+			v.Speed *= factor
+			fmt.Fprintf(v.W, "Speed: %gx\n", v.Speed)
+			d = time.Duration(float64(d-time.Since(started)) / factor)
+			started = time.Now()
+			timer.Stop()
+			timer = time.NewTimer(d)
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
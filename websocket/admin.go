@@ -0,0 +1,218 @@
+package websocket
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/he-lium/sokoban/parse"
+)
+
+// The admin API lets operators watch and manage the server over HTTP. Every
+// request must carry the configured admin token as "Authorization: Bearer
+// <token>". The API is only served if a token is configured
+//
+//	GET  /admin/clients                   clients waiting for a game
+//	GET  /admin/games                     games in progress and their boards
+//	POST /admin/games/<id>/end            ends a game now
+//	POST /admin/games/<id>/kick?player=n  removes a player from a game
+//	POST /admin/notice                    sends {"text": ...} to every client
+
+// maxNoticeLength is the most characters allowed in a notice
+const maxNoticeLength = 500
+
+// serveAdmin serves the admin API at /admin/
+func (h *Hub) serveAdmin(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="sokoban admin"`)
+		http.Error(w, "admin token required", http.StatusUnauthorized)
+		return
+	}
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin"), "/"), "/")
+	switch {
+	case len(path) == 1 && path[0] == "clients":
+		if allow(w, r, http.MethodGet) {
+			h.serveAdminClients(w)
+		}
+	case len(path) == 1 && path[0] == "games":
+		if allow(w, r, http.MethodGet) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(parse.AdminGamesJSON(h.inspectGames()))
+		}
+	case len(path) == 3 && path[0] == "games":
+		if allow(w, r, http.MethodPost) {
+			h.serveAdminGame(w, r, path[1], path[2])
+		}
+	case len(path) == 1 && path[0] == "notice":
+		if allow(w, r, http.MethodPost) {
+			h.serveNotice(w, r)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authorized determines whether r carries the admin token
+func (h *Hub) authorized(r *http.Request) bool {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if h.cfg.AdminToken == "" || !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	given := strings.TrimPrefix(auth, prefix)
+	return subtle.ConstantTimeCompare([]byte(given), []byte(h.cfg.AdminToken)) == 1
+}
+
+// allow replies that the method isn't allowed unless r uses the given method
+func allow(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+// serveAdminClients lists the clients in the public queues, private rooms and
+// matches
+func (h *Hub) serveAdminClients(w http.ResponseWriter) {
+	var clients []parse.AdminClient
+	ok := h.call(func(ctx context.Context) {
+		for _, c := range h.waitingClients() {
+			ac := parse.AdminClient{
+				Name:    c.name,
+				Rating:  int(math.Round(h.ratings.get(c.name))),
+				Matched: c.match != nil,
+				Bot:     c.bot,
+			}
+			if c.queue != nil {
+				ac.Queue = c.queue.Name
+			}
+			if c.room != nil {
+				ac.Room = c.room.code
+			}
+			clients = append(clients, ac)
+		}
+	})
+	if !ok {
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(parse.AdminClientsJSON(clients))
+}
+
+// waitingClients returns the clients in the public queues, private rooms and
+// matches. Must be called by the hub
+func (h *Hub) waitingClients() []*client {
+	var clients []*client
+	for _, q := range h.queues {
+		for c := range q.waiting {
+			clients = append(clients, c)
+		}
+	}
+	for _, r := range h.rooms {
+		clients = append(clients, r.players...)
+	}
+	for m := range h.matches {
+		clients = append(clients, m.players...)
+	}
+	return clients
+}
+
+// runningGames returns the Controllers of the games in progress, by id
+func (h *Hub) runningGames() []*Controller {
+	h.gameLock.Lock()
+	defer h.gameLock.Unlock()
+	games := make([]*Controller, 0, len(h.running))
+	for _, c := range h.running {
+		games = append(games, c)
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].id < games[j].id })
+	return games
+}
+
+// inspectGames asks each game in progress for its state, skipping games which
+// finish before replying
+func (h *Hub) inspectGames() []parse.AdminGame {
+	var games []parse.AdminGame
+	for _, c := range h.runningGames() {
+		reply := make(chan parse.AdminGame, 1)
+		if !c.control(receiveInfo{-1, inspect{reply}, nil, nil}) {
+			continue
+		}
+		select {
+		case g := <-reply:
+			games = append(games, g)
+		case <-c.done:
+		}
+	}
+	return games
+}
+
+// serveAdminGame ends a game or kicks one of its players
+func (h *Hub) serveAdminGame(w http.ResponseWriter, r *http.Request, id string, action string) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	h.gameLock.Lock()
+	c, ok := h.running[n]
+	h.gameLock.Unlock()
+	if !ok {
+		http.Error(w, "no game in progress with id "+id, http.StatusNotFound)
+		return
+	}
+
+	switch action {
+	case "end":
+		c.cancel()
+	case "kick":
+		player, err := strconv.Atoi(r.URL.Query().Get("player"))
+		if err != nil || player < 0 || player >= len(c.sender) {
+			http.Error(w, "bad player "+r.URL.Query().Get("player"), http.StatusBadRequest)
+			return
+		}
+		if !c.control(receiveInfo{player, kick{}, nil, nil}) {
+			http.Error(w, "game is over", http.StatusNotFound)
+			return
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveNotice sends a notice to every client waiting for or playing a game
+func (h *Hub) serveNotice(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Text == "" || len([]rune(body.Text)) > maxNoticeLength {
+		http.Error(w, "notice must be 1 to "+strconv.Itoa(maxNoticeLength)+" characters",
+			http.StatusBadRequest)
+		return
+	}
+
+	msg := parse.NoticeJSON(body.Text)
+	h.call(func(ctx context.Context) {
+		for _, c := range h.waitingClients() {
+			c.send(msg)
+		}
+	})
+	for _, c := range h.runningGames() {
+		c.control(receiveInfo{-1, notice{msg}, nil, nil})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,146 @@
+package websocket
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/he-lium/sokoban/parse"
+)
+
+const testToken = "s3cret"
+
+func newAdminServer(t *testing.T) *testServer {
+	cfg := testConfig()
+	cfg.AdminToken = testToken
+	return newTestServer(t, cfg)
+}
+
+func TestAdminAuth(t *testing.T) {
+	s := newAdminServer(t)
+	for _, token := range []string{"", "wrong", testToken + "x"} {
+		resp, _ := s.request(http.MethodGet, "/admin/clients", token, "")
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("token %q got status %d, expected %d", token, resp.StatusCode, http.StatusUnauthorized)
+		}
+		if !strings.HasPrefix(resp.Header.Get("WWW-Authenticate"), "Bearer ") {
+			t.Errorf("token %q got WWW-Authenticate %q", token, resp.Header.Get("WWW-Authenticate"))
+		}
+	}
+
+	s.dial("/ws").expect("queued")
+	resp, body := s.request(http.MethodGet, "/admin/clients", testToken, "")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `"queue":"public"`) {
+		t.Errorf("got status %d with %s, expected the waiting client", resp.StatusCode, body)
+	}
+}
+
+func TestAdminDisabled(t *testing.T) {
+	s := newTestServer(t, testConfig())
+	if resp, _ := s.request(http.MethodGet, "/admin/clients", "", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("got status %d without an admin token configured, expected %d",
+			resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestAdminBadRequests(t *testing.T) {
+	s := newAdminServer(t)
+	s.startGame()
+
+	tables := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{http.MethodPost, "/admin/clients", "", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/admin/games", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/admin/games/0/end", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/admin/notice", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/admin/players", "", http.StatusNotFound},
+		{http.MethodPost, "/admin/games/one/end", "", http.StatusNotFound},
+		{http.MethodPost, "/admin/games/9/end", "", http.StatusNotFound},
+		{http.MethodPost, "/admin/games/0/pause", "", http.StatusNotFound},
+		{http.MethodPost, "/admin/games/0/kick", "", http.StatusBadRequest},
+		{http.MethodPost, "/admin/games/0/kick?player=one", "", http.StatusBadRequest},
+		{http.MethodPost, "/admin/games/0/kick?player=2", "", http.StatusBadRequest},
+		{http.MethodPost, "/admin/games/0/kick?player=-1", "", http.StatusBadRequest},
+		{http.MethodPost, "/admin/notice", `not json`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/notice", `{"text":""}`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/notice",
+			`{"text":"` + strings.Repeat("é", maxNoticeLength+1) + `"}`, http.StatusBadRequest},
+	}
+	for _, table := range tables {
+		resp, body := s.request(table.method, table.path, testToken, table.body)
+		if resp.StatusCode != table.status {
+			t.Errorf("%s %s got status %d (%s), expected %d",
+				table.method, table.path, resp.StatusCode, strings.TrimSpace(body), table.status)
+		}
+		if table.status == http.StatusMethodNotAllowed && resp.Header.Get("Allow") == "" {
+			t.Errorf("%s %s has no Allow header", table.method, table.path)
+		}
+	}
+}
+
+func TestAdminNotice(t *testing.T) {
+	s := newAdminServer(t)
+	clients, _ := s.startGame()
+	waiting := s.dial("/ws")
+	waiting.expect("queued")
+
+	text := strings.Repeat("é", maxNoticeLength)
+	resp, _ := s.request(http.MethodPost, "/admin/notice", testToken, `{"text":"`+text+`"}`)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("got status %d, expected %d", resp.StatusCode, http.StatusNoContent)
+	}
+	for _, c := range append(clients, waiting) {
+		var notice parse.Notice
+		c.expect("notice").decode(t, &notice)
+		if notice.Text != text {
+			t.Errorf("got notice %q, expected %q", notice.Text, text)
+		}
+	}
+}
+
+func TestAdminKickAndEnd(t *testing.T) {
+	cfg := testConfig()
+	cfg.AdminToken = testToken
+	cfg.RoomSize = 3
+	cfg.MinPlayers = 3
+	s := newTestServer(t, cfg)
+	clients, _ := s.startGame()
+
+	resp, _ := s.request(http.MethodPost, "/admin/games/0/kick?player=1", testToken, "")
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("kick got status %d, expected %d", resp.StatusCode, http.StatusNoContent)
+	}
+	clients[1].expectError(parse.Kicked)
+	clients[1].expectClosed()
+
+	var games struct {
+		Games []parse.AdminGame `json:"games"`
+	}
+	resp, body := s.request(http.MethodGet, "/admin/games", testToken, "")
+	testMessage{raw: []byte(body)}.decode(t, &games)
+	if resp.StatusCode != http.StatusOK || len(games.Games) != 1 {
+		t.Fatalf("got status %d listing %s, expected one game", resp.StatusCode, body)
+	}
+	for i, p := range games.Games[0].Players {
+		if p.Left != (i == 1) || p.Connected != (i != 1) {
+			t.Errorf("player %d is listed as %+v after kicking player 1", i, p)
+		}
+	}
+
+	resp, _ = s.request(http.MethodPost, "/admin/games/0/end", testToken, "")
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("end got status %d, expected %d", resp.StatusCode, http.StatusNoContent)
+	}
+	for _, i := range []int{0, 2} {
+		clients[i].expect("game_over")
+		clients[i].expectClosed()
+	}
+	eventually(t, "the game to be unlisted", func() bool {
+		_, body := s.request(http.MethodGet, "/admin/games", testToken, "")
+		return body == `{"games":[]}`
+	})
+}
@@ -0,0 +1,312 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/parse"
+)
+
+// Bots fill the empty seats of a public queue once a player has waited
+// BotWait without enough others joining. A bot is a client without a
+// connection: it reads the messages sent to it and sends its moves through
+// the same channels as a connected client. It plays the solver's solution of
+// its board, or pushes boxes onto targets where it can if the solver gives up.
+// Less skilled bots think for longer and more often make a wrong move, which
+// they then undo
+
+// botSearchStates is the most positions a bot's solver visits
+const botSearchStates = 200000
+
+// bot plays the game of a client created by newBot. owned by its goroutine
+type bot struct {
+	c     *client
+	skill float64
+	rng   *rand.Rand
+
+	me      int
+	turn    int                 // player whose turn it is, -1 if anyone may move
+	board   *sokoban.Board      // the bot's board, updated as its actions succeed
+	plan    []sokoban.Direction // solution from the current board, nil if unsolved
+	plans   chan []sokoban.Direction
+	pending *sokoban.Action // action sent and awaiting its result
+	planned bool            // whether the pending action follows the plan
+	mistake bool            // whether the last action strayed from the plan
+	next    *time.Timer     // fires when the bot makes its next action
+}
+
+// botMessage holds the fields of server messages a bot reacts to
+type botMessage struct {
+	Type   string          `json:"type"`
+	Me     int             `json:"me"`
+	Player int             `json:"player"`
+	Ready  []bool          `json:"ready"`
+	Valid  bool            `json:"move_valid"`
+	Code   string          `json:"code"`
+	Board  json.RawMessage `json:"board"`
+}
+
+// newBot creates a client played by a bot of the configured skill
+func (h *Hub) newBot() *client {
+	c := &client{
+		sendMsg:  make(chan []byte, 5),
+		hub:      h,
+		playerID: -1,
+		bot:      true,
+	}
+	b := &bot{
+		c:     c,
+		skill: h.cfg.BotSkill,
+		rng:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		turn:  -1,
+	}
+	go b.run()
+	return c
+}
+
+// fillWithBots adds bots to q until it has a full room
+func (h *Hub) fillWithBots(ctx context.Context, q *queue) {
+	n := h.cfg.RoomSize - len(q.waiting)
+	log.Printf("queue %s adding %d bots", q.Name, n)
+	for i := 0; i < n; i++ {
+		h.enqueue(ctx, h.newBot(), q)
+	}
+}
+
+// checkBots starts the bot timer of q while it has players waiting but too
+// few to start a game, and stops it otherwise
+func (h *Hub) checkBots(q *queue) {
+	switch {
+	case h.cfg.BotWait == 0 || q.humans() == 0 || len(q.waiting) >= h.cfg.MinPlayers:
+		q.stopBotTimer()
+	case q.botTimer == nil:
+		gen := q.botGen
+		q.botTimer = h.after(h.cfg.BotWait, func(ctx context.Context) {
+			if q.botGen == gen {
+				q.stopBotTimer()
+				h.fillWithBots(ctx, q)
+			}
+		})
+	}
+}
+
+// stopBotTimer stops the bot timer of the queue
+func (q *queue) stopBotTimer() {
+	if q.botTimer != nil {
+		q.botTimer.Stop()
+		q.botTimer = nil
+		q.botGen++
+	}
+}
+
+// humans counts the players waiting in q who aren't bots
+func (q *queue) humans() int {
+	n := 0
+	for c := range q.waiting {
+		if !c.bot {
+			n++
+		}
+	}
+	return n
+}
+
+// humans counts the players of m who aren't bots
+func (m *match) humans() int {
+	n := 0
+	for _, c := range m.players {
+		if !c.bot {
+			n++
+		}
+	}
+	return n
+}
+
+// run reads the messages sent to the bot and plays its moves until the hub or
+// Controller closes its channel
+func (b *bot) run() {
+	defer func() {
+		if b.next != nil {
+			b.next.Stop()
+		}
+	}()
+	for {
+		var move <-chan time.Time
+		if b.next != nil {
+			move = b.next.C
+		}
+		select {
+		case msg, ok := <-b.c.sendMsg:
+			if !ok {
+				return
+			}
+			b.handle(msg)
+		case plan := <-b.plans:
+			b.plans = nil
+			b.plan = plan
+			b.schedule(2)
+		case <-move:
+			b.next = nil
+			b.act()
+		}
+	}
+}
+
+// handle reacts to a message sent to the bot
+func (b *bot) handle(msg []byte) {
+	var m botMessage
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return
+	}
+	switch m.Type {
+	case "match":
+		if m.Me < len(m.Ready) && !m.Ready[m.Me] {
+			b.c.deliver(parse.Ready{Header: parse.Header{Type: "ready"}})
+		}
+	case "game_init":
+		b.start(m.Me, m.Board)
+	case "turn":
+		b.turn = m.Player
+		b.schedule(1)
+	case "action_result":
+		if m.Player == b.me && b.pending != nil {
+			b.result(m.Valid)
+		}
+	case "win":
+		if m.Player == b.me {
+			b.stop()
+		}
+	case "error":
+		b.pending = nil
+		if m.Code == parse.GameOver {
+			// e.g. out of moves
+			b.stop()
+			return
+		}
+		// e.g. not the bot's turn; try again later, or when it is
+		b.schedule(1)
+	}
+}
+
+// start sets up the bot's board and works out its solution in the background,
+// as the first move waits for it
+func (b *bot) start(me int, content json.RawMessage) {
+	b.me = me
+	gen := &parse.JSONBoard{JSONContent: content}
+	board, err := gen.GenBoard()
+	if err != nil {
+		log.Printf("bot %p: unable to read board: %s", b.c, err.Error())
+		return
+	}
+	b.board = board
+	solve, _ := gen.GenBoard()
+	b.plans = make(chan []sokoban.Direction, 1)
+	go func(plans chan<- []sokoban.Direction) {
+		plan, ok := sokoban.Solve(solve, botSearchStates)
+		if !ok {
+			log.Printf("bot %p: no solution found, playing by heuristic", b.c)
+		}
+		plans <- plan
+	}(b.plans)
+}
+
+// stop makes no more actions once the bot has finished
+func (b *bot) stop() {
+	b.board = nil
+	if b.next != nil {
+		b.next.Stop()
+		b.next = nil
+	}
+}
+
+// schedule arranges the bot's next action after a human-like pause, scaled
+// by factor and lengthened for less skilled bots
+func (b *bot) schedule(factor float64) {
+	if b.board == nil || b.plans != nil || b.pending != nil || b.next != nil {
+		return
+	}
+	pause := 200*time.Millisecond + time.Duration((1-b.skill)*float64(800*time.Millisecond))
+	jitter := 0.5 + b.rng.Float64()
+	b.next = time.NewTimer(time.Duration(factor * jitter * float64(pause)))
+}
+
+// act sends the bot's next action: undoing a mistake, a wrong move now and
+// then, or the next move of the plan
+func (b *bot) act() {
+	if b.board == nil || b.pending != nil || (b.turn >= 0 && b.turn != b.me) {
+		return
+	}
+	var a sokoban.Action
+	switch {
+	case b.mistake:
+		a = sokoban.Action{Type: sokoban.Undo}
+	case len(b.plan) > 0 && b.rng.Float64() < b.skill:
+		a = sokoban.Action{Type: sokoban.Move, Direction: b.plan[0]}
+		b.planned = true
+	case len(b.plan) > 0:
+		a = sokoban.Action{Type: sokoban.Move, Direction: sokoban.Direction(b.rng.Intn(4))}
+		b.planned = a.Direction == b.plan[0]
+	default:
+		a = sokoban.Action{Type: sokoban.Move, Direction: b.heuristic()}
+		b.planned = false
+	}
+	b.pending = &a
+
+	var msg parse.Message
+	if a.Type == sokoban.Undo {
+		msg = parse.Undo{Header: parse.Header{Type: "undo"}}
+	} else {
+		msg = parse.Move{Header: parse.Header{Type: "move"}, Direction: sokoban.DirectionToStr(a.Direction)}
+	}
+	b.c.deliver(msg)
+}
+
+// result updates the bot's board with the result of its pending action
+func (b *bot) result(valid bool) {
+	a := *b.pending
+	b.pending = nil
+	switch {
+	case a.Type == sokoban.Undo:
+		if valid {
+			b.board.UndoMove()
+		}
+		b.mistake = false
+	case !valid && b.planned:
+		// the board isn't what the plan expected
+		b.plan = nil
+	case valid:
+		b.board.MakeMove(a.Direction)
+		if b.planned {
+			b.plan = b.plan[1:]
+		} else if len(b.plan) > 0 {
+			b.mistake = true
+		}
+	}
+	b.schedule(1)
+}
+
+// heuristic picks a move which covers the most targets, choosing at random
+// between equally good moves
+func (b *bot) heuristic() sokoban.Direction {
+	best, bestScore, ties := sokoban.Up, -1, 0
+	for d := sokoban.Up; d <= sokoban.Left; d++ {
+		if !b.board.MakeMove(d) {
+			continue
+		}
+		score := b.board.GetScore()
+		b.board.UndoMove()
+		switch {
+		case score > bestScore:
+			best, bestScore, ties = d, score, 1
+		case score == bestScore:
+			ties++
+			if b.rng.Intn(ties) == 0 {
+				best = d
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,46 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/he-lium/sokoban/parse"
+)
+
+func TestBotFillsQueue(t *testing.T) {
+	cfg := testConfig()
+	cfg.BotWait = 50 * time.Millisecond
+	cfg.BotSkill = 1
+	s := newTestServer(t, cfg)
+
+	// a lone player is joined by a bot once they have waited BotWait
+	c := s.dial("/ws")
+	var init parse.GameInit
+	c.expect("game_init").decode(t, &init)
+	if init.NPlayers != 2 || init.Me != 0 {
+		t.Fatalf("got game_init %+v, expected player 0 of 2", init)
+	}
+	games := s.hub.inspectGames()
+	if len(games) != 1 || len(games[0].Players) != 2 || games[0].Players[0].Bot || !games[0].Players[1].Bot {
+		t.Fatalf("inspected %+v, expected a game of a player and a bot", games)
+	}
+
+	// the bot's moves go through the Controller like any other player's,
+	// until it solves the level
+	if m := c.expect("opponent_action"); m.Player != 1 {
+		t.Fatalf("got %s, expected the bot to move", m.raw)
+	}
+	if m := c.expect("win"); m.Player != 1 {
+		t.Fatalf("got %s, expected the bot to win", m.raw)
+	}
+	for _, d := range solution3 {
+		c.send(`{"type":"move","direction":"` + d + `"}`)
+		c.expect("action_result")
+	}
+	var result parse.GameResult
+	c.expect("game_over").decode(t, &result)
+	if !reflect.DeepEqual(result.Order, []int{1, 0}) {
+		t.Errorf("got result %+v, expected the bot then player 0 to finish", result)
+	}
+}
@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/he-lium/sokoban/parse"
+)
+
+// Players can chat and send emotes to everyone in the same game, private room
+// or public queue. Each client may send a burst of chatBurst messages, then
+// one every chatInterval
+
+const (
+	chatBurst    = 5
+	chatInterval = 2 * time.Second
+)
+
+// chatLimiter is a token bucket limiting the rate a client chats. owned by the
+// client's incoming goroutine
+type chatLimiter struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the bucket, returning false if it is empty
+func (l *chatLimiter) allow(now time.Time) bool {
+	if l.last.IsZero() {
+		l.tokens = chatBurst
+	} else {
+		l.tokens += float64(now.Sub(l.last)) / float64(chatInterval)
+		if l.tokens > chatBurst {
+			l.tokens = chatBurst
+		}
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// isChat determines whether msg is a chat message or emote
+func isChat(msg parse.Message) bool {
+	switch msg.(type) {
+	case parse.Chat, parse.Emote:
+		return true
+	}
+	return false
+}
+
+// chatJSON generates the JSON relaying a chat message or emote from player
+func chatJSON(player int, msg parse.Message) []byte {
+	switch m := msg.(type) {
+	case parse.Chat:
+		return parse.ChatJSON(player, m.Text)
+	case parse.Emote:
+		return parse.EmoteJSON(player, m.Emote)
+	}
+	return nil
+}
+
+// relayChat sends a chat message or emote from a client who is waiting for a
+// game to everyone in the same match, room or public queue
+func (h *Hub) relayChat(c *client, msg parse.Message) {
+	if m := c.match; m != nil {
+		j := chatJSON(m.index(c), msg)
+		for _, p := range m.players {
+			p.send(j)
+		}
+		return
+	}
+	if r := c.room; r != nil {
+		for i, p := range r.players {
+			if p == c {
+				j := chatJSON(i, msg)
+				for _, p := range r.players {
+					p.send(j)
+				}
+				return
+			}
+		}
+	}
+	j := chatJSON(-1, msg)
+	for p := range c.queue.waiting {
+		p.send(j)
+	}
+}
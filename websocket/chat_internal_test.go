@@ -0,0 +1,121 @@
+package websocket
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/he-lium/sokoban/parse"
+)
+
+func TestChatLimiter(t *testing.T) {
+	tables := []struct {
+		at    time.Duration // since the first message
+		allow bool
+	}{
+		// a burst of chatBurst messages is allowed
+		{0, true},
+		{0, true},
+		{0, true},
+		{0, true},
+		{0, true},
+		{0, false},
+		{chatInterval / 2, false},
+		// then one every chatInterval
+		{chatInterval, true},
+		{chatInterval, false},
+		{2 * chatInterval, true},
+		// tokens build up to chatBurst again while quiet
+		{100 * chatInterval, true},
+		{100 * chatInterval, true},
+		{100 * chatInterval, true},
+		{100 * chatInterval, true},
+		{100 * chatInterval, true},
+		{100 * chatInterval, false},
+	}
+	var l chatLimiter
+	start := time.Now()
+	for i, table := range tables {
+		if allow := l.allow(start.Add(table.at)); allow != table.allow {
+			t.Errorf("message %d at %v: allowed %t, expected %t", i, table.at, allow, table.allow)
+		}
+	}
+}
+
+func TestChatInGame(t *testing.T) {
+	s := newTestServer(t, testConfig())
+	clients, _ := s.startGame()
+
+	// the longest chat fits in maxMsgSize with every character escaped
+	longest := `{"type":"chat","text":"` + strings.Repeat(`\ud83d\ude00`, parse.MaxChatLength) + `"}`
+	if len(longest) > maxMsgSize {
+		t.Fatalf("longest chat is %d bytes, more than maxMsgSize %d", len(longest), maxMsgSize)
+	}
+
+	tables := []struct {
+		msg  string
+		typ  string // type of the message relayed to every player
+		code string // expected error code, empty if relayed
+	}{
+		{`{"type":"chat","text":"gl hf"}`, "chat", ""},
+		{longest, "chat", ""},
+		{`{"type":"emote","emote":"gg"}`, "emote", ""},
+		{`{"type":"chat","text":""}`, "", parse.BadChat},
+		{`{"type":"chat","text":"` + strings.Repeat("a", parse.MaxChatLength+1) + `"}`, "", parse.BadChat},
+		{`{"type":"emote","emote":"dance"}`, "", parse.BadChat},
+	}
+	for _, table := range tables {
+		clients[1].send(table.msg)
+		if table.code != "" {
+			clients[1].expectError(table.code)
+			continue
+		}
+		for _, c := range clients {
+			if m := c.expect(table.typ); m.Player != 1 {
+				t.Errorf("sending %s relayed %s, expected it from player 1", table.msg, m.raw)
+			}
+		}
+	}
+	clients[0].expectNone("error", 50*time.Millisecond)
+}
+
+func TestChatRateLimit(t *testing.T) {
+	s := newTestServer(t, testConfig())
+	clients, _ := s.startGame()
+	for i := 0; i < chatBurst; i++ {
+		clients[0].send(`{"type":"emote","emote":"wave"}`)
+		clients[0].expect("emote")
+	}
+	clients[0].send(`{"type":"chat","text":"one too many"}`)
+	clients[0].expectError(parse.RateLimited)
+	clients[1].expectNone("chat", 50*time.Millisecond)
+}
+
+func TestChatWaiting(t *testing.T) {
+	s := newRoomServer(t)
+
+	// players in the public queue chat anonymously
+	a, b := s.dial("/ws"), s.dial("/ws")
+	a.expect("queued")
+	b.expect("queued")
+	a.send(`{"type":"chat","text":"anyone?"}`)
+	for _, c := range []*testClient{a, b} {
+		if m := c.expect("chat"); m.Player != -1 {
+			t.Errorf("got %s in the public queue, expected player -1", m.raw)
+		}
+	}
+
+	// players in a room chat as their index in the room, unheard by the queue
+	a.send(`{"type":"create_room","min_players":2,"max_players":3}`)
+	r := expectRoom(t, a, 1, 0)
+	c := s.dial("/ws")
+	c.send(`{"type":"join_room","code":"` + r.Code + `"}`)
+	expectRoom(t, c, 2, 1)
+	c.send(`{"type":"emote","emote":"wave"}`)
+	for _, c := range []*testClient{a, c} {
+		if m := c.expect("emote"); m.Player != 1 {
+			t.Errorf("got %s in a room, expected player 1", m.raw)
+		}
+	}
+	b.expectNone("emote", 50*time.Millisecond)
+}
@@ -2,38 +2,40 @@ package websocket
 
 import (
 	"bytes"
-	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/he-lium/sokoban/parse"
 )
 
 type status int
 
-const (
-	// writeWait  = 10 * time.Second  // time allowed to write msg
-	// pongWait   = 60 * time.Second  // time allowed to read next pong msg
-	writeWait  = 2 * time.Second   // debugging
-	pongWait   = 2 * time.Second   // debugging
-	pingPeriod = pongWait * 9 / 10 // interval to send pings
-	maxMsgSize = 512
-)
+const maxMsgSize = 512
 
 var newline = []byte{'\n'}
 var space = []byte{' '}
 
 // Client is a representation of a websocket connection with a client
 type client struct {
-	sendMsg    chan []byte // channel of outbound messages
-	conn       *websocket.Conn
-	hub        *Hub
-	controller *Controller
-	playerID   int        // assigned at start of game
-	isPlaying  bool       // whether the game has started with a Controller
-	playLock   sync.Mutex // mutex for initial setup of Controller
+	sendMsg     chan []byte // channel of outbound messages
+	conn        *websocket.Conn
+	hub         *Hub
+	controller  *Controller
+	playerID    int         // assigned at start of game
+	isPlaying   bool        // whether the game has started with a Controller
+	playLock    sync.Mutex  // mutex for initial setup of Controller
+	room        *room       // private room joined, if any. owned by hub
+	match       *match      // match waiting to be ready, if any. owned by hub
+	queue       *queue      // public queue joined. owned by hub
+	name        string      // nickname the player joined with. owned by hub
+	bot         bool        // whether the client is a bot played by the server
+	seq         uint64      // sequence number of the last message sent
+	unsupported bool        // whether the client said hello with an unsupported version
+	chat        chatLimiter // rate limit of chat messages. owned by incoming
 }
 
 var upgrader = websocket.Upgrader{
@@ -45,38 +47,30 @@ var upgrader = websocket.Upgrader{
 func (c *client) incoming() {
 	// cleanup
 	defer func() {
-		if !c.isPlaying {
-			c.hub.deregister <- c
+		c.hub.metrics.connected.Dec()
+		if !c.playing() {
+			c.hub.deregisterClient(c)
 		} else {
 			// TODO disconnect from Controller if still playing
-			j := map[string]interface{}{
-				"action": "disconnect",
-			}
-			c.controller.receiver <- receiveInfo{c.playerID, j}
+			c.controller.control(receiveInfo{c.playerID, disconnect{}, c, nil})
 		}
+		// released once the Controller has been told of the disconnect, so a
+		// client joining with the nickname finds the player dropped
+		c.hub.identities.release(c)
 		log.Printf("player %d client reader closed\n", c.playerID)
 		c.conn.Close()
 	}()
 
 	// set up
 	c.conn.SetReadLimit(maxMsgSize)
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
-	c.conn.SetPongHandler(func(string) error {
+	c.conn.SetReadDeadline(time.Now().Add(c.hub.cfg.PongWait))
+	c.conn.SetPongHandler(func(appData string) error {
 		log.Printf("pong handler for player %d in %p", c.playerID, c.controller)
-		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.hub.metrics.pong(appData)
+		c.conn.SetReadDeadline(time.Now().Add(c.hub.cfg.PongWait))
 		return nil
 	})
 	for {
-		// determine whether still waiting for game to start
-		if !c.isPlaying {
-			c.playLock.Lock()
-			if c.controller != nil {
-				log.Printf("Client: started game %p as player %d\n", c.controller, c.playerID)
-				c.isPlaying = true
-			}
-			c.playLock.Unlock()
-		}
-
 		_, msg, err := c.conn.ReadMessage()
 		if err != nil {
 			log.Printf("player %d client read error: %v", c.playerID, err)
@@ -87,36 +81,67 @@ func (c *client) incoming() {
 			break
 		}
 
-		if !c.isPlaying {
-			continue
-		}
 		msg = bytes.TrimSpace(bytes.Replace(msg, newline, space, -1))
 
 		log.Printf("client %p:%d: %s\n", c.controller, c.playerID, msg)
 
-		// Parse JSON message, check for action field
-		var data map[string]interface{}
-		err = json.Unmarshal(msg, &data)
+		if c.unsupported {
+			c.reject(parse.ErrorJSON(parse.UnsupportedVersion, "protocol version not supported"))
+			continue
+		}
+		data, err := parse.DecodeClientMessage(msg)
 		if err != nil {
-			// TODO reply with error msg to client
+			e := err.(*parse.DecodeError)
+			c.hub.metrics.rejected.With(e.Code).Inc()
+			c.reject(parse.ErrorJSON(e.Code, e.Message))
+			continue
+		}
+		if hello, ok := data.(parse.Hello); ok {
+			// version handshake
+			if hello.Version < parse.MinProtocolVersion || hello.Version > parse.ProtocolVersion {
+				c.unsupported = true
+				c.reject(parse.ErrorJSON(parse.UnsupportedVersion,
+					fmt.Sprintf("server speaks protocol versions %d to %d",
+						parse.MinProtocolVersion, parse.ProtocolVersion)))
+			}
 			continue
 		}
-		action, ok1 := data["action"]
-		_, ok2 := action.(string)
-		if !(ok1 && ok2) {
-			// TODO reply with error msg to client
+		if isChat(data) && !c.chat.allow(time.Now()) {
+			c.reject(parse.ErrorJSON(parse.RateLimited, "sending chat too quickly"))
 			continue
 		}
 
-		// send action to game Controller
-		c.controller.receiver <- receiveInfo{c.playerID, data}
+		c.deliver(data)
 	}
 }
 
+// deliver passes a message from the client to its game Controller, or to the
+// hub while still waiting for the game to start
+func (c *client) deliver(data parse.Message) {
+	if !c.playing() {
+		c.hub.request(c, data, nil)
+		return
+	}
+	c.controller.control(receiveInfo{c.playerID, data, c, nil})
+}
+
+// playing determines whether the hub has started the client's game
+func (c *client) playing() bool {
+	if !c.isPlaying {
+		c.playLock.Lock()
+		if c.controller != nil {
+			log.Printf("Client: started game %p as player %d\n", c.controller, c.playerID)
+			c.isPlaying = true
+		}
+		c.playLock.Unlock()
+	}
+	return c.isPlaying
+}
+
 // Process messages to be sent to the peer. Can send one or more JSON objects in
 // a message, separated by newline.
 func (c *client) outgoing() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(c.hub.cfg.pingPeriod())
 	defer func() {
 		ticker.Stop()
 		log.Printf("player %d client writer closed\n", c.playerID)
@@ -126,7 +151,7 @@ func (c *client) outgoing() {
 	for {
 		select {
 		case msg, ok := <-c.sendMsg:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.cfg.WriteWait))
 			if !ok {
 				// channel closed
 				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
@@ -138,13 +163,13 @@ func (c *client) outgoing() {
 				// TODO log
 				return
 			}
-			w.Write(msg)
+			w.Write(c.stamp(msg))
 
 			// Send queued logs to the message
 			n := len(c.sendMsg)
 			for i := 0; i < n; i++ {
 				w.Write(newline)
-				w.Write(<-c.sendMsg)
+				w.Write(c.stamp(<-c.sendMsg))
 			}
 
 			if err := w.Close(); err != nil {
@@ -153,8 +178,8 @@ func (c *client) outgoing() {
 			}
 
 		case <-ticker.C: // time to send ping to peer
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			err := c.conn.WriteMessage(websocket.PingMessage, nil)
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.cfg.WriteWait))
+			err := c.conn.WriteMessage(websocket.PingMessage, pingData())
 			log.Printf("sending ping msg for player %d in %p", c.playerID, c.controller)
 			if err != nil {
 				// TODO log
@@ -164,6 +189,13 @@ func (c *client) outgoing() {
 	}
 }
 
+// stamp numbers each outgoing message so the peer can detect gaps and ask for
+// the full state of the game
+func (c *client) stamp(msg []byte) []byte {
+	c.seq++
+	return parse.WithSeq(msg, c.seq)
+}
+
 func serveWsClient(hub *Hub, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -171,6 +203,7 @@ func serveWsClient(hub *Hub, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log.Printf("websocket client connected from %s\n", r.RemoteAddr)
+	hub.metrics.connected.Inc()
 
 	client := &client{
 		sendMsg:  make(chan []byte, 5),
@@ -178,9 +211,35 @@ func serveWsClient(hub *Hub, w http.ResponseWriter, r *http.Request) {
 		hub:      hub,
 		playerID: -1,
 	}
-	hub.register <- client
+	client.sendMsg <- parse.HelloJSON()
+	if token := r.URL.Query().Get("token"); token == "" {
+		hub.registerClient(client)
+	} else if !hub.reconnect(token, client, false) {
+		client.sendMsg <- parse.ReconnectFailedJSON()
+		hub.registerClient(client)
+	}
 
 	// start message sender and receivers
 	go client.incoming()
 	go client.outgoing()
 }
+
+// reject replies to input which couldn't be parsed. The reply is passed to
+// the hub or Controller, which own the client's outbound channel
+func (c *client) reject(reply []byte) {
+	if c.playing() {
+		c.controller.control(receiveInfo{c.playerID, nil, c, reply})
+	} else {
+		c.hub.request(c, nil, reply)
+	}
+}
+
+// send queues a message to the client while waiting in the hub, dropping it
+// if the client is unresponsive
+func (c *client) send(msg []byte) {
+	select {
+	case c.sendMsg <- msg:
+	default:
+		log.Printf("hub: dropped message to unresponsive client %p", c)
+	}
+}
@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/leaderboard"
+	"github.com/he-lium/sokoban/replay"
+)
+
+// MaxRoomSize is the most players allowed in a game
+const MaxRoomSize = 8
+
+// Config holds the settings of the websocket server and its Hub
+type Config struct {
+	Addr      string        // address to listen on, e.g. ":8080"
+	PongWait  time.Duration // time allowed to read the next pong from a client
+	WriteWait time.Duration // time allowed to write a message to a client
+
+	ReconnectGrace time.Duration // time a dropped player's slot is held
+	StateInterval  time.Duration // time between full state messages, 0 for never
+
+	RoomSize   int           // most players in a game; starts once reached
+	MinPlayers int           // fewest players a game may start with
+	LobbyWait  time.Duration // time to wait for a full room once MinPlayers join
+	BotWait    time.Duration // time a player waits before bots fill the room, 0 for no bots
+	BotSkill   float64       // how well bots play, from 0 (at random) to 1 (perfectly)
+
+	ReadyTimeout time.Duration // time matched players have to be ready, 0 for no check
+	Countdown    int           // seconds counted down once all players are ready
+
+	LevelName   string            // description of the levels of the "public" queue
+	Queues      []Queue           // public queues offered besides "public"
+	RatingsFile string            // JSON file ratings are saved to; empty to keep in memory
+	AuthFile    string            // JSON file claimed nicknames are saved to; empty to keep in memory
+	AdminToken  string            // bearer token of the admin API; empty to disable it
+	Records     leaderboard.Store // where solutions are recorded for leaderboards, may be nil
+	Replays     replay.Store      // where games are recorded, may be nil
+	GameTimeout time.Duration     // longest time a game may be played
+	Limits      sokoban.Limits    // time, move and push limits of each game
+	Schedule    sokoban.Schedule  // turn order of each game
+
+	GhostInterval time.Duration // time between the moves of a ghost of the solver's solution
+}
+
+// DefaultConfig returns the Config used when no settings are given
+func DefaultConfig() Config {
+	return Config{
+		Addr:           ":8080",
+		PongWait:       60 * time.Second,
+		WriteWait:      10 * time.Second,
+		ReconnectGrace: 30 * time.Second,
+		StateInterval:  30 * time.Second,
+		RoomSize:       2,
+		MinPlayers:     2,
+		LobbyWait:      30 * time.Second,
+		BotWait:        time.Minute,
+		BotSkill:       0.8,
+		ReadyTimeout:   20 * time.Second,
+		Countdown:      3,
+		GameTimeout:    30 * time.Minute,
+		GhostInterval:  300 * time.Millisecond,
+	}
+}
+
+// Validate returns an error if the settings of c can't be used
+func (c Config) Validate() error {
+	switch {
+	case c.PongWait <= 0 || c.WriteWait <= 0:
+		return errors.New("config: pong and write timeouts must be positive")
+	case c.RoomSize < 1 || c.RoomSize > MaxRoomSize:
+		return fmt.Errorf("config: room size must be between 1 and %d", MaxRoomSize)
+	case c.MinPlayers < 1 || c.MinPlayers > c.RoomSize:
+		return errors.New("config: min players must be between 1 and room size")
+	case c.BotWait < 0 || c.BotSkill < 0 || c.BotSkill > 1:
+		return errors.New("config: bot wait must not be negative and bot skill must be between 0 and 1")
+	case c.ReadyTimeout < 0 || c.Countdown < 0:
+		return errors.New("config: ready timeout and countdown must not be negative")
+	case c.LobbyWait < 0 || c.ReconnectGrace < 0 || c.StateInterval < 0:
+		return errors.New("config: lobby wait, reconnect grace and state interval must not be negative")
+	case c.GameTimeout <= 0 || c.GhostInterval <= 0:
+		return errors.New("config: game timeout and ghost interval must be positive")
+	}
+	names := map[string]bool{"public": true}
+	for _, q := range c.Queues {
+		switch {
+		case q.Name == "" || names[q.Name]:
+			return fmt.Errorf("config: queue names must be unique and not empty, got %q", q.Name)
+		case q.Gen == nil:
+			return fmt.Errorf("config: queue %s has no levels", q.Name)
+		}
+		names[q.Name] = true
+	}
+	return nil
+}
+
+// pingPeriod returns the interval at which to send pings to clients
+func (c Config) pingPeriod() time.Duration {
+	return c.PongWait * 9 / 10
+}
@@ -1,33 +1,102 @@
 package websocket
 
 import (
+	"context"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/leaderboard"
 	"github.com/he-lium/sokoban/parse"
+	"github.com/he-lium/sokoban/replay"
 )
 
 // Controller implements sokoban.Controller by communicating to user(s) via
 // Go channels
 type Controller struct {
-	receiver  chan receiveInfo // channel where users send their inputs
-	sender    []*client        // slice of channels to send the results
-	nPlaying  int              // number of players who haven't left the game
-	connected []bool           // bit table of players connected to server
-	won       []bool           // bit table of players who have won
+	id        int                // assigned by the hub
+	gen       sokoban.BoardMaker // generates the board of the game
+	level     string             // description of the levels played
+	started   time.Time          // when the hub started the game
+	cancel    func()             // ends the game early
+	names     []string           // nickname of each player, empty if unrated
+	receiver  chan receiveInfo   // channel where users send their inputs, never closed
+	done      chan struct{}      // closed once the game has stopped receiving
+	sending   sync.RWMutex       // held for reading while sending to receiver
+	sender    []*client          // slice of channels to send the results
+	nPlaying  int                // number of players who haven't left the game
+	connected []bool             // bit table of players connected to server
+	won       []bool             // bit table of players who have won
+	left      []bool             // bit table of players who have left for good
+	bots      []bool             // bit table of players who are bots
+
+	tokens  []string         // session token of each player for reconnecting
+	forget  func(p int)      // forgets the session of a player who left for good
+	grace   time.Duration    // time a dropped player's slot is held
+	dropped []time.Time      // when each player dropped, zero if not dropped
+	start   *sokoban.Board   // copy of the starting board
+	boards  []*sokoban.Board // current board of each player, nil until moved
+
+	spectators map[*client]bool // read-only clients watching the game
+
+	stateEvery  time.Duration // time between full state messages, 0 for never
+	stateTicker *time.Ticker  // started on the first call to RecvInput
+
+	ghost *replay.Ghost // plays as the last player, if not nil
+
+	metrics *serverMetrics
 }
 
 type receiveInfo struct {
 	player int
-	json   map[string]interface{}
+	msg    interface{} // parse.Message from the player, or a hub message below
+	client *client     // connection the message came from
+	reply  []byte      // if set, an error to send back instead of acting
 }
 
+// messages sent to the Controller on behalf of the hub
+type (
+	disconnect struct{} // player's connection has closed
+	spectate   struct{} // client has started watching the game
+	unspectate struct{} // spectator has stopped watching the game
+	kick       struct{} // admin has removed the player from the game
+
+	// reconnect asks to attach a new connection of the player, replying
+	// whether it was attached. By nickname, only a dropped player's slot is
+	// taken over
+	reconnect struct {
+		byName bool
+		reply  chan<- bool
+	}
+
+	// inspect asks for the state of the game for the admin API
+	inspect struct {
+		reply chan<- parse.AdminGame
+	}
+
+	// notice is sent to every player and spectator
+	notice struct {
+		msg []byte
+	}
+
+	// ghostAction is the next action of the game's ghost
+	ghostAction struct {
+		sokoban.Action
+	}
+)
+
 var _ sokoban.Controller = (*Controller)(nil)
+var _ sokoban.LimitReporter = (*Controller)(nil)
+var _ sokoban.TurnNotifier = (*Controller)(nil)
+var _ sokoban.ActionRejecter = (*Controller)(nil)
+var _ leaderboard.BestNotifier = (*Controller)(nil)
 
 // Init broadcasts the initial game board to each user
 func (c *Controller) Init(b *sokoban.Board) {
+	c.start = b.Clone()
 	for i := range c.sender {
-		j, err := parse.InitBoardJSON(c.nPlaying, i, b)
+		j, err := parse.InitBoardJSON(len(c.sender), i, b, c.tokens[i], c.names)
 		if err == nil {
 			c.sendTo(i, j)
 		} else {
@@ -36,85 +105,151 @@ func (c *Controller) Init(b *sokoban.Board) {
 	}
 }
 
-// RecvInput receives an action from the user
-// precondition: "action" is a valid json string
-func (c *Controller) RecvInput() (int, sokoban.Action) {
-	req := <-c.receiver
-	var a sokoban.Action
-	action := req.json["action"].(string)
-
-	switch action {
-	case "undo":
-		a.Type = sokoban.Undo
-	case "reset":
-		a.Type = sokoban.Reset
-	case "move":
-		a.Type = sokoban.Move
-		var direction = ""
-		dirField := req.json["direction"]
-		if dirField != nil {
-			d, ok := dirField.(string)
-			if ok {
-				direction = d
-			}
+// RecvInput receives an action from the user, or returns ctx.Err() once ctx
+// is done. Players who disconnect leave the game once their slot has been
+// held for the grace period without reconnecting
+// precondition: messages from players have been decoded by parse
+func (c *Controller) RecvInput(ctx context.Context) (int, sokoban.Action, error) {
+	for {
+		var req receiveInfo
+		select {
+		case req = <-c.receiver:
+		case <-c.graceTimer():
+			p := c.expired()
+			log.Printf("game %p controller: player %d did not reconnect", c, p)
+			return p, sokoban.Action{Type: sokoban.Leave}, nil
+		case <-c.stateTick():
+			c.broadcastState()
+			continue
+		case <-ctx.Done():
+			return -1, sokoban.Action{}, ctx.Err()
 		}
 
-		switch direction {
-		case "up":
-			a.Direction = sokoban.Up
-		case "down":
-			a.Direction = sokoban.Down
-		case "left":
-			a.Direction = sokoban.Left
-		case "right":
-			a.Direction = sokoban.Right
-		default: // invalid direction
-			a.Direction = -1
+		if req.reply != nil {
+			// input was rejected before reaching the controller
+			if req.client == c.sender[req.player] {
+				c.sendTo(req.player, req.reply)
+			}
+			continue
 		}
-	case "disconnect":
-		// controller action: player has disconnected
-		if c.connected[req.player] {
-			c.connected[req.player] = false
-			close(c.sender[req.player].sendMsg)
-			if !c.won[req.player] {
+
+		switch m := req.msg.(type) {
+		case disconnect:
+			if req.client == c.sender[req.player] {
+				c.drop(req.player)
+			}
+		case reconnect:
+			m.reply <- c.reattach(req.player, req.client, m.byName)
+		case spectate:
+			c.addSpectator(req.client)
+		case unspectate:
+			c.removeSpectator(req.client)
+		case inspect:
+			m.reply <- c.inspect()
+		case notice:
+			c.broadcast(m.msg)
+		case kick:
+			if c.kick(req.player) {
+				return req.player, sokoban.Action{Type: sokoban.Leave}, nil
+			}
+		case ghostAction:
+			p := req.player
+			if m.Type == sokoban.Leave {
+				if c.won[p] || c.left[p] {
+					continue
+				}
+				c.left[p] = true
 				c.nPlaying--
 			}
+			return p, m.Action, nil
+		case parse.StateRequest:
+			// player requested the full state after missing messages
+			c.sendTo(req.player, parse.StateJSON(req.player, c.start, c.currentBoards()))
+		case parse.Chat, parse.Emote:
+			if req.client != c.sender[req.player] {
+				// from a connection replaced by a reconnect
+				continue
+			}
+			c.broadcast(chatJSON(req.player, m.(parse.Message)))
+		case parse.ActionMessage:
+			if req.client != c.sender[req.player] {
+				// from a connection replaced by a reconnect
+				continue
+			}
+			a := m.Action()
+			log.Printf("game %p controller: player %d %s %s", c, req.player,
+				sokoban.ActionTypeToStr(a.Type), sokoban.DirectionToStr(a.Direction))
+			return req.player, a, nil
+		case parse.Message:
+			c.sendTo(req.player, parse.ErrorJSON(parse.UnknownAction,
+				"cannot "+m.MessageType()+" during a game"))
 		}
-	default:
-		// invalid action
 	}
+}
 
-	log.Printf("game %p controller: player %d %s %s",
-		c, req.player, action, sokoban.DirectionToStr(a.Direction))
-	return req.player, a
+// RejectAction tells the player why their action was not attempted
+func (c *Controller) RejectAction(player int, a sokoban.Action, reason error) {
+	code := parse.GameOver
+	if reason == sokoban.ErrNotYourTurn {
+		code = parse.NotYourTurn
+	}
+	c.metrics.rejected.With(code).Inc()
+	c.sendTo(player, parse.ErrorJSON(code, reason.Error()))
 }
 
 // SendResult sends the result of an action to user making the action
 // and, if successful, broadcasts to all players
 func (c *Controller) SendResult(player int, success bool, a sokoban.Action) {
 	// send result to the origin player
-	c.sendTo(player, parse.ActionResult(player, success))
+	c.sendTo(player, parse.ActionResultJSON(player, success))
 	if success {
+		msg := parse.OpponentActionJSON(player, c.names[player], a)
 		for i := range c.sender {
 			if i != player {
-				c.sendTo(i, parse.OpponentAction(player, a))
+				c.sendTo(i, msg)
 			}
 		}
+		c.sendSpectators(msg)
 	}
 }
 
 // OutputBoard broadcasts game winners
 func (c *Controller) OutputBoard(player int, b *sokoban.Board) {
-	// TODO
-	if b.Won() {
+	c.boards[player] = b
+	if b.Won() && !c.won[player] {
 		c.won[player] = true
-		for i := range c.sender {
-			c.sendTo(i, parse.WinResultJSON(player))
-		}
+		c.broadcast(parse.WinResultJSON(player, c.names[player]))
 		c.nPlaying--
 	}
 }
 
+// NotifyBest tells the player which of their records they have beaten
+func (c *Controller) NotifyBest(player int, best []leaderboard.Ranking) {
+	records := make([]string, len(best))
+	for i, by := range best {
+		records[i] = leaderboard.RankingToStr(by)
+	}
+	c.sendTo(player, parse.PersonalBestJSON(player, records))
+}
+
+// ReportLimits sends the player their remaining time, moves and pushes
+func (c *Controller) ReportLimits(player int, s sokoban.LimitStatus) {
+	c.sendTo(player, parse.LimitsJSON(player, s))
+}
+
+// NotifyTurn tells every player whose turn it is
+func (c *Controller) NotifyTurn(player int) {
+	c.broadcast(parse.TurnJSON(player))
+}
+
+// broadcast sends msg to every connected player and spectator
+func (c *Controller) broadcast(msg []byte) {
+	for i := range c.sender {
+		c.sendTo(i, msg)
+	}
+	c.sendSpectators(msg)
+}
+
 func (c *Controller) sendTo(p int, msg []byte) {
 	// attempt to send to sender goroutine, disconnecting if failed
 	if c.connected[p] {
@@ -122,21 +257,196 @@ func (c *Controller) sendTo(p int, msg []byte) {
 		case c.sender[p].sendMsg <- msg:
 		default:
 			log.Printf("controller %p: player %d unresponsive", c, p)
-			c.connected[p] = false
-			close(c.sender[p].sendMsg)
-			if !c.won[p] {
-				c.nPlaying--
-			}
+			c.metrics.dropped.Inc()
+			c.drop(p)
+		}
+	}
+}
+
+// drop disconnects player p, holding their slot for the grace period unless
+// they have already won
+func (c *Controller) drop(p int) {
+	if !c.connected[p] {
+		return
+	}
+	c.connected[p] = false
+	close(c.sender[p].sendMsg)
+	if !c.won[p] && !c.left[p] {
+		c.dropped[p] = time.Now()
+	}
+}
+
+// graceTimer returns a channel which fires when the earliest grace period of
+// the dropped players runs out, or nil if no players have dropped
+func (c *Controller) graceTimer() <-chan time.Time {
+	var first time.Time
+	for _, t := range c.dropped {
+		if !t.IsZero() && (first.IsZero() || t.Before(first)) {
+			first = t
+		}
+	}
+	if first.IsZero() {
+		return nil
+	}
+	return time.After(time.Until(first.Add(c.grace)))
+}
+
+// expired removes the dropped player whose grace period ran out first from
+// the game, returning the player
+func (c *Controller) expired() int {
+	p := -1
+	for i, t := range c.dropped {
+		if !t.IsZero() && (p < 0 || t.Before(c.dropped[p])) {
+			p = i
+		}
+	}
+	c.dropped[p] = time.Time{}
+	c.left[p] = true
+	c.nPlaying--
+	c.forget(p)
+	return p
+}
+
+// kick removes player p from the game for good, returning whether they were
+// still playing
+func (c *Controller) kick(p int) bool {
+	if c.left[p] {
+		return false
+	}
+	log.Printf("game %p controller: player %d kicked", c, p)
+	c.sendTo(p, parse.ErrorJSON(parse.Kicked, "removed from the game by an admin"))
+	c.drop(p)
+	c.dropped[p] = time.Time{}
+	c.left[p] = true
+	c.forget(p)
+	if c.won[p] {
+		return false
+	}
+	c.nPlaying--
+	return true
+}
+
+// inspect describes the game and the boards of its players
+func (c *Controller) inspect() parse.AdminGame {
+	g := parse.AdminGame{
+		ID:         c.id,
+		Level:      c.level,
+		Started:    c.started,
+		Spectators: len(c.spectators),
+		Players:    make([]parse.AdminPlayer, len(c.sender)),
+	}
+	for i, b := range c.currentBoards() {
+		g.Players[i] = parse.AdminPlayer{
+			Player:    i,
+			Name:      c.names[i],
+			Bot:       c.bots[i] || i == c.ghostPlayer(),
+			Connected: c.connected[i],
+			Won:       c.won[i],
+			Left:      c.left[i],
+			Board:     parse.NewBoardState(b),
+		}
+	}
+	return g
+}
+
+// reattach connects a client which reconnected as player p, replacing any
+// previous connection and resending the state of the game. Returns false if
+// p has finished, or if p rejoined by nickname without having dropped
+func (c *Controller) reattach(p int, cl *client, byName bool) bool {
+	if c.left[p] || c.won[p] || (byName && c.dropped[p].IsZero()) {
+		return false
+	}
+	if c.connected[p] {
+		close(c.sender[p].sendMsg)
+	}
+	log.Printf("game %p controller: player %d reconnected", c, p)
+	c.sender[p] = cl
+	c.connected[p] = true
+	c.dropped[p] = time.Time{}
+
+	c.sendTo(p, parse.ResyncJSON(p, c.start, c.currentBoards()))
+	return true
+}
+
+// stateTick returns a channel which fires whenever the full state of the game
+// is due to be sent, or nil if it is only sent on request
+func (c *Controller) stateTick() <-chan time.Time {
+	if c.stateEvery <= 0 {
+		return nil
+	}
+	if c.stateTicker == nil {
+		c.stateTicker = time.NewTicker(c.stateEvery)
+	}
+	return c.stateTicker.C
+}
+
+// broadcastState sends the full state of the game to every player and
+// spectator
+func (c *Controller) broadcastState() {
+	boards := c.currentBoards()
+	for i := range c.sender {
+		c.sendTo(i, parse.StateJSON(i, c.start, boards))
+	}
+	c.sendSpectators(parse.StateJSON(-1, c.start, boards))
+}
+
+// currentBoards returns the current board of each player
+func (c *Controller) currentBoards() []*sokoban.Board {
+	boards := make([]*sokoban.Board, len(c.boards))
+	for i, b := range c.boards {
+		boards[i] = b
+		if b == nil {
+			boards[i] = c.start
 		}
 	}
+	return boards
 }
 
-// Closing returns whether the game should stop
+// control sends a message to the Controller on behalf of a client or the hub,
+// returning false if the game has already finished. Every send to receiver
+// must go through control
+func (c *Controller) control(info receiveInfo) bool {
+	c.sending.RLock()
+	defer c.sending.RUnlock()
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.receiver <- info:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// stopReceiving closes done once no message is being sent, so that every
+// message control has accepted is already in receiver
+func (c *Controller) stopReceiving() {
+	c.sending.Lock()
+	defer c.sending.Unlock()
+	close(c.done)
+}
+
+// Closing returns whether the game should stop. A ghost or bots don't keep
+// the game going once every other player has finished
 func (c *Controller) Closing() bool {
-	if c.nPlaying <= 0 {
-		// TODO
+	playing := c.nPlaying
+	for p := range c.sender {
+		if (p == c.ghostPlayer() || c.bots[p]) && !c.won[p] && !c.left[p] {
+			playing--
+		}
 	}
-	return c.nPlaying <= 0
+	return playing <= 0
 	// After game has ended, caller is responsible for
 	// handling channels and Websocket connections
 }
+
+// ghostPlayer returns the player the ghost plays as, or -1 if there is none
+func (c *Controller) ghostPlayer() int {
+	if c.ghost == nil {
+		return -1
+	}
+	return len(c.sender) - 1
+}
@@ -0,0 +1,68 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/parse"
+)
+
+func TestRejectedInput(t *testing.T) {
+	cfg := testConfig()
+	cfg.Schedule = sokoban.Schedule{Mode: sokoban.Alternate}
+	s := newTestServer(t, cfg)
+	clients, _ := s.startGame()
+
+	// only the sender is told why its message was rejected
+	tables := []struct {
+		player int
+		msg    string
+		code   string
+	}{
+		{0, `{"type":"move"`, parse.BadJSON},
+		{0, `["move"]`, parse.BadJSON},
+		{0, `{"type":"move","direction":1}`, parse.BadJSON},
+		{1, `{}`, parse.UnknownAction},
+		{1, `{"type":"fly"}`, parse.UnknownAction},
+		{1, `{"type":"create_room"}`, parse.UnknownAction},
+		{0, `{"type":"move","direction":"north"}`, parse.BadDirection},
+		{1, `{"type":"move","direction":"up"}`, parse.NotYourTurn},
+		{1, `{"type":"undo"}`, parse.NotYourTurn},
+	}
+	for _, table := range tables {
+		clients[table.player].send(table.msg)
+		clients[table.player].expectError(table.code)
+		clients[1-table.player].expectNone("error", 50*time.Millisecond)
+	}
+
+	// player 0 solves the level, taking turns with player 1, then may not move
+	for i, d := range solution3 {
+		clients[0].send(`{"type":"move","direction":"` + d + `"}`)
+		clients[0].expect("action_result")
+		if i == len(solution3)-1 {
+			break
+		}
+		expectTurn(t, clients[1], 1)
+		if i%2 == 0 {
+			clients[1].send(`{"type":"move","direction":"up"}`)
+		} else {
+			clients[1].send(`{"type":"undo"}`)
+		}
+		var r parse.ActionResult
+		if clients[1].expect("action_result").decode(t, &r); !r.Valid {
+			t.Fatalf("got %+v, expected player 1's action to succeed", r)
+		}
+	}
+	clients[0].expect("win")
+	clients[0].send(`{"type":"move","direction":"up"}`)
+	clients[0].expectError(parse.GameOver)
+	clients[1].expectNone("error", 50*time.Millisecond)
+}
+
+// expectTurn skips messages until c is told it is the turn of player
+func expectTurn(t *testing.T, c *testClient, player int) {
+	t.Helper()
+	for c.expect("turn").Player != player {
+	}
+}
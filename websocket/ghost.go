@@ -0,0 +1,83 @@
+package websocket
+
+import (
+	"context"
+
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/parse"
+	"github.com/he-lium/sokoban/replay"
+)
+
+// raceGhost starts a game for c against a ghost of the winner of the recorded
+// game with the given replay ID, played on the same level
+func (h *Hub) raceGhost(ctx context.Context, c *client, id string) {
+	if c.room != nil {
+		c.send(parse.ErrorJSON(parse.RoomRefused, "leave the room before racing a ghost"))
+		return
+	}
+	if h.cfg.Replays == nil {
+		c.send(parse.ErrorJSON(parse.NoSuchReplay, "games are not recorded on this server"))
+		return
+	}
+	content, err := h.cfg.Replays.Get(id)
+	if err != nil {
+		c.send(parse.ErrorJSON(parse.NoSuchReplay, "no replay with id "+id))
+		return
+	}
+	r, err := replay.Load(content)
+	if err != nil {
+		c.send(parse.ErrorJSON(parse.NoSuchReplay, err.Error()))
+		return
+	}
+	ghost, err := replay.GhostFromReplay(r, -1)
+	if err != nil {
+		c.send(parse.ErrorJSON(parse.NoSuchReplay, err.Error()))
+		return
+	}
+
+	h.removeWaiting(ctx, c)
+	levels := Queue{Name: "ghost", Level: "replay " + id, Gen: &parse.JSONBoard{JSONContent: r.Board}}
+	h.startNewGame(ctx, []*client{c}, levels, ghost)
+}
+
+// raceSolver starts a game for c against a ghost of the solver's solution of
+// the next level of c's public queue. The level is solved in the background,
+// and the game starts once a solution is found if c is still waiting
+func (h *Hub) raceSolver(ctx context.Context, c *client) {
+	if c.room != nil {
+		c.send(parse.ErrorJSON(parse.RoomRefused, "leave the room before racing a ghost"))
+		return
+	}
+	q := c.queue
+	board, err := q.Gen.GenBoard()
+	if err != nil {
+		c.send(parse.ErrorJSON(parse.NoSuchReplay, "unable to make a level: "+err.Error()))
+		return
+	}
+	content, err := parse.BoardToJSON(board)
+	if err != nil {
+		c.send(parse.ErrorJSON(parse.NoSuchReplay, "unable to make a level: "+err.Error()))
+		return
+	}
+
+	go func() {
+		moves, ok := sokoban.Solve(board, botSearchStates)
+		h.after(0, func(ctx context.Context) {
+			if !c.waiting() {
+				return // disconnected or matched meanwhile
+			}
+			if !ok {
+				c.send(parse.ErrorJSON(parse.NoSuchReplay, "no solution found for the level"))
+				return
+			}
+			ghost, err := replay.GhostFromMoves(moves, h.cfg.GhostInterval)
+			if err != nil {
+				c.send(parse.ErrorJSON(parse.NoSuchReplay, err.Error()))
+				return
+			}
+			h.removeWaiting(ctx, c)
+			levels := Queue{Name: "ghost", Level: q.Level + " solver", Gen: &parse.JSONBoard{JSONContent: content}}
+			h.startNewGame(ctx, []*client{c}, levels, ghost)
+		})
+	}()
+}
@@ -0,0 +1,46 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/he-lium/sokoban/parse"
+)
+
+func TestRaceSolver(t *testing.T) {
+	cfg := testConfig()
+	cfg.RoomSize = MaxRoomSize
+	cfg.MinPlayers = MaxRoomSize
+	cfg.GhostInterval = time.Millisecond
+	s := newTestServer(t, cfg)
+
+	// players in a room can't race a ghost
+	host := s.dial("/ws")
+	host.send(`{"type":"create_room"}`)
+	expectRoom(t, host, 1, 0)
+	host.send(`{"type":"race_ghost","solver":true}`)
+	host.expectError(parse.RoomRefused)
+
+	c := s.dial("/ws")
+	c.expect("queued")
+	c.send(`{"type":"race_ghost","solver":true}`)
+	var init parse.GameInit
+	c.expect("game_init").decode(t, &init)
+	if init.NPlayers != 2 || init.Me != 0 {
+		t.Fatalf("got game_init %+v, expected player 0 of 2", init)
+	}
+
+	// the ghost solves the level as player 1 while player 0 waits
+	if m := c.expect("win"); m.Player != 1 {
+		t.Fatalf("got %s, expected the ghost to win", m.raw)
+	}
+	for _, d := range solution3 {
+		c.send(`{"type":"move","direction":"` + d + `"}`)
+		c.expect("action_result")
+	}
+	var result parse.GameResult
+	c.expect("game_over").decode(t, &result)
+	if len(result.Order) != 2 || result.Order[0] != 1 || result.Order[1] != 0 {
+		t.Errorf("got result %+v, expected the ghost then player 0 to finish", result)
+	}
+}
@@ -1,32 +1,103 @@
 package websocket
 
 import (
+	"context"
+	"embed"
+	"io/fs"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/parse"
 )
 
-const listenAddr = ":8080"
+// static holds the browser client served at the root of the server
+//
+//go:embed static
+var static embed.FS
 
-// Serve starts the Server for connecting over websocket
-func Serve(gen sokoban.BoardMaker) {
-	hub := NewHub(gen)
+const shutdownWait = 5 * time.Second // time allowed for http requests to finish
 
-	go hub.Run()
+// Serve starts the Server for connecting over websocket, configured by cfg.
+// Once ctx is done, the server stops accepting connections, cancels running
+// games and returns after they have finished
+func Serve(ctx context.Context, gen sokoban.BoardMaker, cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	hub := NewHub(gen, cfg)
+	if err := hub.ratings.load(); err != nil {
+		return err
+	}
+	if err := hub.identities.load(); err != nil {
+		return err
+	}
+
+	go hub.Run(ctx)
+
+	srv := &http.Server{Addr: cfg.Addr, Handler: hub.handler()}
+
+	go func() {
+		<-ctx.Done()
+		log.Println("shutting down server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownWait)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Println("ListenAndServe at ", cfg.Addr)
+	err := srv.ListenAndServe()
+	if err != http.ErrServerClosed {
+		return err
+	}
+	hub.Wait()
+	return nil
+}
 
-	http.HandleFunc("/", serveHome)
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		serveWsClient(hub, w, r)
+// handler routes the HTTP requests of the server
+func (h *Hub) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", serveHome())
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		serveWsClient(h, w, r)
 	})
-	log.Println("ListenAndServe at ", listenAddr)
-	err := http.ListenAndServe(":8080", nil)
+	mux.HandleFunc("/ws/spectate", func(w http.ResponseWriter, r *http.Request) {
+		serveSpectator(h, w, r)
+	})
+	mux.Handle("/lobby", h.lobby)
+	mux.HandleFunc("/ws/lobby", func(w http.ResponseWriter, r *http.Request) {
+		serveLobbyWs(h, w, r)
+	})
+	mux.HandleFunc("/protocol/schema.json", serveSchema)
+	mux.Handle("/metrics", h.metrics.registry)
+	if h.cfg.Records != nil {
+		mux.HandleFunc("/leaderboard", serveLeaderboards(h.cfg.Records))
+		mux.HandleFunc("/leaderboard/", serveLeaderboards(h.cfg.Records))
+	}
+	if h.cfg.Replays != nil {
+		mux.HandleFunc("/replays", serveReplays(h.cfg.Replays))
+		mux.HandleFunc("/replays/", serveReplays(h.cfg.Replays))
+	}
+	if h.cfg.AdminToken != "" {
+		mux.HandleFunc("/admin/", h.serveAdmin)
+	}
+	return mux
+}
+
+// serveHome serves the embedded browser client
+func serveHome() http.Handler {
+	files, err := fs.Sub(static, "static")
 	if err != nil {
-		log.Fatal("ListenAndServe: ", err)
+		log.Fatal("serveHome: ", err)
 	}
+	return http.FileServer(http.FS(files))
 }
 
-func serveHome(w http.ResponseWriter, r *http.Request) {
-	// TODO serve website
-	http.Error(w, "Not found", http.StatusNotFound)
+// serveSchema serves the JSON Schema of the websocket protocol
+func serveSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(schema)
 }
+
+var schema = parse.ProtocolSchema()
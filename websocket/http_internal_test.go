@@ -0,0 +1,242 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/he-lium/sokoban/mock"
+)
+
+// helpers for running a Hub behind a test server and talking to it as clients
+
+// testWait is the longest time a test waits for a message
+const testWait = 2 * time.Second
+
+// testConfig returns a Config which starts games as soon as two players are
+// matched, without a ready check, countdown or bots
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.ReadyTimeout = 0
+	cfg.Countdown = 0
+	cfg.BotWait = 0
+	cfg.StateInterval = 0
+	cfg.LevelName = "mock3"
+	return cfg
+}
+
+// testServer serves a running Hub playing mock board 3
+type testServer struct {
+	*httptest.Server
+	t   *testing.T
+	hub *Hub
+}
+
+// newTestServer starts a Hub configured by cfg, stopping it once the test ends
+func newTestServer(t *testing.T, cfg Config) *testServer {
+	t.Helper()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid config: %s", err.Error())
+	}
+	hub := NewHub(mock.BoardMaker3{}, cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	go hub.Run(ctx)
+	srv := httptest.NewServer(hub.handler())
+	t.Cleanup(func() {
+		cancel()
+		hub.Wait()
+		srv.Close()
+	})
+	return &testServer{srv, t, hub}
+}
+
+// request makes an HTTP request to the server, returning the response and its
+// body. A token is given as the bearer token, if not empty
+func (s *testServer) request(method, path, token, body string) (*http.Response, string) {
+	s.t.Helper()
+	req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	if err != nil {
+		s.t.Fatalf("error creating request: %s", err.Error())
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatalf("%s %s: %s", method, path, err.Error())
+	}
+	defer resp.Body.Close()
+	content, _ := ioutil.ReadAll(resp.Body)
+	return resp, string(content)
+}
+
+// startGame connects clients until the public queue starts a game, returning
+// them by player index with the game_init message each was sent
+func (s *testServer) startGame() ([]*testClient, []testMessage) {
+	s.t.Helper()
+	n := s.hub.cfg.RoomSize
+	dialled := make([]*testClient, n)
+	for i := range dialled {
+		dialled[i] = s.dial("/ws")
+	}
+	clients := make([]*testClient, n)
+	inits := make([]testMessage, n)
+	for _, c := range dialled {
+		init := c.expect("game_init")
+		if init.Me < 0 || init.Me >= n || clients[init.Me] != nil {
+			s.t.Fatalf("bad player index in %s", init.raw)
+		}
+		clients[init.Me] = c
+		inits[init.Me] = init
+	}
+	return clients, inits
+}
+
+// testMessage holds the fields of a server message which tests commonly check
+type testMessage struct {
+	Type   string `json:"type"`
+	Code   string `json:"code"`
+	Me     int    `json:"me"`
+	Player int    `json:"player"`
+	Token  string `json:"token"`
+	Reason string `json:"reason"`
+	raw    []byte
+}
+
+// decode parses the whole message into v
+func (m testMessage) decode(t *testing.T, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(m.raw, v); err != nil {
+		t.Fatalf("error parsing %s: %s", m.raw, err.Error())
+	}
+}
+
+// testClient is a websocket connection to a testServer. Messages are read as
+// they arrive, so pings are answered while the test waits
+type testClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+	msgs chan testMessage // closed once the connection closes
+}
+
+// dial connects a client to the websocket endpoint at path
+func (s *testServer) dial(path string) *testClient {
+	s.t.Helper()
+	url := "ws" + strings.TrimPrefix(s.URL, "http") + path
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		s.t.Fatalf("error dialling %s: %s", path, err.Error())
+	}
+	c := &testClient{s.t, conn, make(chan testMessage, 100)}
+	go c.read()
+	s.t.Cleanup(func() { conn.Close() })
+	return c
+}
+
+func (c *testClient) read() {
+	defer close(c.msgs)
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		for _, line := range bytes.Split(data, newline) {
+			var m testMessage
+			json.Unmarshal(line, &m)
+			m.raw = line
+			c.msgs <- m
+		}
+	}
+}
+
+// send sends the JSON message
+func (c *testClient) send(msg string) {
+	c.t.Helper()
+	if err := c.conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+		c.t.Fatalf("error sending %s: %s", msg, err.Error())
+	}
+}
+
+// expect skips messages until one of the given type arrives
+func (c *testClient) expect(typ string) testMessage {
+	c.t.Helper()
+	timeout := time.After(testWait)
+	for {
+		select {
+		case m, ok := <-c.msgs:
+			if !ok {
+				c.t.Fatalf("connection closed waiting for %s", typ)
+			}
+			if m.Type == typ {
+				return m
+			}
+		case <-timeout:
+			c.t.Fatalf("timed out waiting for %s", typ)
+		}
+	}
+}
+
+// expectError skips messages until an error arrives, failing unless it has
+// the given code
+func (c *testClient) expectError(code string) testMessage {
+	c.t.Helper()
+	m := c.expect("error")
+	if m.Code != code {
+		c.t.Fatalf("got error %s, expected %s", m.raw, code)
+	}
+	return m
+}
+
+// expectNone fails if a message of the given type arrives within d
+func (c *testClient) expectNone(typ string, d time.Duration) {
+	c.t.Helper()
+	timeout := time.After(d)
+	for {
+		select {
+		case m, ok := <-c.msgs:
+			if !ok {
+				return
+			}
+			if m.Type == typ {
+				c.t.Fatalf("unexpected message %s", m.raw)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// expectClosed skips messages until the server closes the connection
+func (c *testClient) expectClosed() {
+	c.t.Helper()
+	timeout := time.After(testWait)
+	for {
+		select {
+		case _, ok := <-c.msgs:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			c.t.Fatal("timed out waiting for the connection to close")
+		}
+	}
+}
+
+// eventually fails unless cond becomes true within testWait
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testWait)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
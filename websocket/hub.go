@@ -1,96 +1,330 @@
 package websocket
 
 import (
+	"context"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/leaderboard"
+	"github.com/he-lium/sokoban/parse"
+	"github.com/he-lium/sokoban/replay"
 )
 
-const maxRoomPlayers = 2
-
-// Hub matches websocket clients to start the game
+// Hub matches websocket clients to start the game, either from the public
+// queues of waiting clients or from private rooms
 type Hub struct {
 	register   chan *client
 	deregister chan *client
-	gen        sokoban.BoardMaker // board generator
-	waiting    map[*client]bool   // clients waiting to play
+	requests   chan hubRequest // messages from clients not yet playing
+	cfg        Config
+	queues     []*queue                   // public queues; new clients join the first
+	ratings    *ratings                   // ratings of players by nickname
+	identities *identities                // claimed and connected nicknames
+	rooms      map[string]*room           // private rooms by join code
+	matches    map[*match]bool            // matched clients waiting to be ready
+	timers     chan func(context.Context) // timers which have fired, and admin calls
+	lobby      *lobby                     // listing of rooms and games
+	metrics    *serverMetrics             // served at /metrics
+	nextGameID int
+
+	running  map[int]*Controller // games in progress by id
+	sessions map[string]session  // players of games in progress by token
+	gameLock sync.Mutex          // guards running and sessions
+	done     chan struct{}       // closed once the hub stops running
+	games    sync.WaitGroup      // games still being played
 }
 
-// NewHub initialises a waiting hub with given BoardMaker for making new games
-func NewHub(gen sokoban.BoardMaker) *Hub {
-	return &Hub{
+// NewHub initialises a waiting hub with given BoardMaker for making the games
+// of the "public" queue, followed by the queues of cfg, and Config for sizing
+// and running them
+func NewHub(gen sokoban.BoardMaker, cfg Config) *Hub {
+	queues := []*queue{newQueue(Queue{"public", cfg.LevelName, gen})}
+	for _, q := range cfg.Queues {
+		queues = append(queues, newQueue(q))
+	}
+	h := &Hub{
 		register:   make(chan *client),
 		deregister: make(chan *client),
-		waiting:    make(map[*client]bool),
-		gen:        gen,
+		requests:   make(chan hubRequest),
+		queues:     queues,
+		ratings:    newRatings(cfg.RatingsFile),
+		identities: newIdentities(cfg.AuthFile),
+		rooms:      make(map[string]*room),
+		matches:    make(map[*match]bool),
+		timers:     make(chan func(context.Context)),
+		lobby:      newLobby(),
+		running:    make(map[int]*Controller),
+		sessions:   make(map[string]session),
+		done:       make(chan struct{}),
+		cfg:        cfg,
 	}
+	h.metrics = newServerMetrics(h)
+	return h
 }
 
-// Run starts the hub, receiving connections and spinning off games
-func (h *Hub) Run() {
+// Run starts the hub, receiving connections and spinning off games until ctx
+// is done. Games in progress are cancelled along with ctx
+func (h *Hub) Run(ctx context.Context) {
+	defer h.stop()
+	h.updateLobby()
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case newClient := <-h.register:
-			h.waiting[newClient] = true
-			log.Printf("client joined hub. now %d players", len(h.waiting))
-			if len(h.waiting) >= maxRoomPlayers {
-				// enough people have joined; assign Controller and start game
-				h.startNewGame()
-			}
+			h.enqueue(ctx, newClient, h.queues[0])
 		case delClient := <-h.deregister: // quit before playing
-			if _, ok := h.waiting[delClient]; ok {
-				delete(h.waiting, delClient)
+			if delClient.room != nil {
+				h.leaveRoom(delClient)
+				close(delClient.sendMsg)
+			} else if delClient.match != nil {
+				h.leaveMatch(ctx, delClient)
+				close(delClient.sendMsg)
+			} else if delClient.waiting() {
+				h.removeWaiting(ctx, delClient)
 				close(delClient.sendMsg)
-				log.Printf("client left hub. now %d players", len(h.waiting))
 			}
+		case req := <-h.requests:
+			h.handleRequest(ctx, req)
+		case f := <-h.timers:
+			f(ctx)
 		}
+		h.updateLobby()
+		h.metrics.waiting.Set(float64(len(h.waitingClients())))
+	}
+}
+
+// after arranges for f to be run by the hub after d. f must check that the
+// timer is still wanted, as it may already be waiting when the timer is stopped
+func (h *Hub) after(d time.Duration, f func(ctx context.Context)) *time.Timer {
+	return time.AfterFunc(d, func() {
+		select {
+		case h.timers <- f:
+		case <-h.done:
+		}
+	})
+}
+
+// call runs f on the hub and waits for it to return. Returns false without
+// running f if the hub has stopped
+func (h *Hub) call(f func(ctx context.Context)) bool {
+	done := make(chan struct{})
+	select {
+	case h.timers <- func(ctx context.Context) {
+		f(ctx)
+		close(done)
+	}:
+		<-done
+		return true
+	case <-h.done:
+		return false
+	}
+}
+
+// Wait blocks until every game started by the hub has finished
+func (h *Hub) Wait() {
+	h.games.Wait()
+}
+
+// stop disconnects waiting clients and refuses any new ones
+func (h *Hub) stop() {
+	close(h.done)
+	for _, q := range h.queues {
+		q.stopTimer()
+		q.stopBotTimer()
+		for c := range q.waiting {
+			delete(q.waiting, c)
+			close(c.sendMsg)
+		}
+	}
+	for m := range h.matches {
+		h.endMatch(m)
+		for _, c := range m.players {
+			close(c.sendMsg)
+		}
+	}
+	for code, r := range h.rooms {
+		delete(h.rooms, code)
+		r.stopTimer()
+		for _, c := range r.players {
+			close(c.sendMsg)
+		}
+	}
+	h.lobby.closeWatchers()
+	log.Println("hub stopped")
+}
+
+// registerClient adds a newly connected client to the hub, disconnecting it if
+// the hub has stopped
+func (h *Hub) registerClient(c *client) {
+	select {
+	case h.register <- c:
+	case <-h.done:
+		close(c.sendMsg)
 	}
 }
 
-func (h *Hub) startNewGame() {
-	// enough people have joined; assign Controller and start game
-	numPlayers := len(h.waiting)
+// deregisterClient removes a client which quit before its game started
+func (h *Hub) deregisterClient(c *client) {
+	select {
+	case h.deregister <- c:
+	case <-h.done:
+	}
+}
 
+// request passes a message from a client not yet playing to the hub, or an
+// error reply to send back to the client
+func (h *Hub) request(c *client, msg parse.Message, reply []byte) {
+	select {
+	case h.requests <- hubRequest{c, msg, reply}:
+	case <-h.done:
+	}
+}
+
+// startNewGame assigns a Controller to the given clients and starts a game on
+// the levels of q. If ghost is not nil, it plays as the last player
+func (h *Hub) startNewGame(ctx context.Context, clients []*client, q Queue, ghost *replay.Ghost) {
+	numPlayers := len(clients)
+	if ghost != nil {
+		numPlayers++
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.GameTimeout)
 	ctrl := &Controller{
+		id:        h.nextGameID,
+		gen:       q.Gen,
+		level:     q.Level,
+		started:   time.Now(),
+		cancel:    cancel,
+		ghost:     ghost,
+		names:     make([]string, numPlayers),
 		receiver:  make(chan receiveInfo, numPlayers+1),
+		done:      make(chan struct{}),
 		sender:    make([]*client, numPlayers),
 		nPlaying:  numPlayers,
 		connected: make([]bool, numPlayers),
 		won:       make([]bool, numPlayers),
+		left:      make([]bool, numPlayers),
+		bots:      make([]bool, numPlayers),
+
+		spectators: make(map[*client]bool),
+		tokens:     make([]string, numPlayers),
+		grace:      h.cfg.ReconnectGrace,
+		stateEvery: h.cfg.StateInterval,
+		dropped:    make([]time.Time, numPlayers),
+		boards:     make([]*sokoban.Board, numPlayers),
+		metrics:    h.metrics,
 	}
+	ctrl.forget = func(p int) { h.forgetSession(ctrl.tokens[p]) }
 
-	i := 0
-	// connect client to controller and remove client from hub
-	for c := range h.waiting {
+	// connect client to controller
+	h.gameLock.Lock()
+	h.running[ctrl.id] = ctrl
+	for i, c := range clients {
 		ctrl.sender[i] = c
 		ctrl.connected[i] = true
+		ctrl.tokens[i] = newToken()
+		ctrl.names[i] = c.name
+		ctrl.bots[i] = c.bot
+		h.sessions[ctrl.tokens[i]] = session{ctrl, i}
 
 		c.playLock.Lock()
 		c.controller = ctrl
 		c.playerID = i
 		c.playLock.Unlock()
-		delete(h.waiting, c)
-		i++
 	}
+	h.gameLock.Unlock()
+	h.nextGameID++
+	h.lobby.addGame(parse.LobbyGame{
+		ID:      ctrl.id,
+		Players: numPlayers,
+		Playing: numPlayers,
+		Level:   q.Level,
+		Started: ctrl.started,
+	})
 	log.Printf("startNewGame: starting new game with %d players\n", numPlayers)
 	// handle playing in separate goroutine
-	go runGame(ctrl, h.gen)
+	h.games.Add(1)
+	go func() {
+		defer h.games.Done()
+		defer cancel()
+		h.runGame(ctx, ctrl)
+	}()
 }
 
-func runGame(c *Controller, gen sokoban.BoardMaker) {
-	defer onFinishGame(c)
+// runGame plays the game of c until it finishes, ctx is done or the game
+// timeout runs out
+func (h *Hub) runGame(ctx context.Context, c *Controller) {
+	defer h.onFinishGame(c)
 
-	game, err := sokoban.InitGame(c.nPlaying, gen, c)
+	game, err := sokoban.InitGame(c.nPlaying, c.gen, c)
 	if err != nil {
 		log.Printf("Hub: ERROR when creating game: %s\n", err.Error())
 		return
 	}
-	game.Play()
+	game.SetLimits(h.cfg.Limits)
+	game.SetSchedule(h.cfg.Schedule)
+	game.AddObserver(gameLogger(c))
+	game.AddObserver(h.lobbyObserver(c.id))
+	game.AddObserver(h.metrics.observer())
+	if c.ghost != nil {
+		go c.ghost.Play(ctx, func(a sokoban.Action) bool {
+			return c.control(receiveInfo{c.ghostPlayer(), ghostAction{a}, nil, nil})
+		})
+	}
+	if h.cfg.Records != nil {
+		game.AddObserver(leaderboard.NewRecorder(h.cfg.Records, c.names, c))
+	}
+	if h.cfg.Replays != nil {
+		game.AddObserver(replay.NewRecorder(h.cfg.Replays))
+	}
+	result := game.PlayContext(ctx)
+	c.broadcast(parse.GameOverJSON(result))
+	if err := h.ratings.update(c.names, result); err != nil {
+		log.Printf("Hub: ERROR saving ratings: %s\n", err.Error())
+	}
 }
 
-func onFinishGame(c *Controller) {
+// gameLogger logs the progress of the game played through c
+func gameLogger(c *Controller) sokoban.Observer {
+	return sokoban.ObserverFunc(func(e sokoban.Event) {
+		switch e := e.(type) {
+		case sokoban.GameStarted:
+			log.Printf("game %p started with %d players\n", c, e.NPlayers)
+		case sokoban.PlayerWon:
+			log.Printf("game %p: player %d finished at rank %d\n", c, e.Player, e.Rank)
+		case sokoban.PlayerLeft:
+			log.Printf("game %p: player %d left\n", c, e.Player)
+		case sokoban.PlayerTimedOut:
+			log.Printf("game %p: player %d ran out of time\n", c, e.Player)
+		case sokoban.PlayerLimitReached:
+			log.Printf("game %p: player %d used up their moves or pushes\n", c, e.Player)
+		case sokoban.GameEnded:
+			r := e.Result
+			log.Printf("game %p over after %v, finish order %v\n", c, r.Duration, r.Order)
+			for i, p := range r.Players {
+				log.Printf("game %p: player %d %s (rank %d) in %v, %d moves, %d pushes\n",
+					c, i, sokoban.PlayerStatusToStr(p.Status), p.Rank, p.Elapsed,
+					p.Moves, p.Pushes)
+			}
+		}
+	})
+}
+
+func (h *Hub) onFinishGame(c *Controller) {
 	log.Printf("Finished game %p. disconnecting players...\n", c)
+	// messages sent from now on are refused, so nothing needs to wait for
+	// the clients to disconnect
+	go receiveDisconnects(c)
+	c.stopReceiving()
+	if c.stateTicker != nil {
+		c.stateTicker.Stop()
+	}
+	h.lobby.removeGame(c.id)
+	h.endSessions(c)
+	h.metrics.finished.Inc()
 	// disconnect clients still playing
 	for i := range c.sender {
 		if c.connected[i] {
@@ -98,19 +332,31 @@ func onFinishGame(c *Controller) {
 			close(c.sender[i].sendMsg)
 		}
 	}
-	go receiveDisconnects(c)
-	// Wait for clients to send disconnect messages before closing channel
-	time.Sleep(pongWait)
-	close(c.receiver)
+	for s := range c.spectators {
+		delete(c.spectators, s)
+		close(s.sendMsg)
+	}
 	log.Printf("Disconnected game %p.\n", c)
 }
 
-// receive disconnect calls
+// receiveDisconnects turns away the clients which reconnected or started
+// spectating as the game finished, returning once c has stopped receiving and
+// every message sent to it has been handled. The connections of players are
+// closed by onFinishGame
 func receiveDisconnects(c *Controller) {
-	for info := range c.receiver {
-		if c.connected[info.player] {
-			c.connected[info.player] = false
-			close(c.sender[info.player].sendMsg)
+	for {
+		select {
+		case info := <-c.receiver:
+			switch m := info.msg.(type) {
+			case reconnect:
+				m.reply <- false
+			case spectate:
+				close(info.client.sendMsg)
+			}
+		case <-c.done:
+			if len(c.receiver) == 0 {
+				return
+			}
 		}
 	}
 }
@@ -0,0 +1,144 @@
+package websocket
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/he-lium/sokoban/parse"
+)
+
+// Players identify themselves with "join", giving a nickname. The first
+// player to join with a nickname claims it and is sent a token, which must be
+// given to join with that nickname again. Only one connected client may use a
+// nickname at a time. A player who joins with the nickname of a dropped player
+// of a game in progress takes over their slot, as if they had reconnected with
+// their session token
+
+var (
+	errNameInUse     = errors.New("nickname is already in use")
+	errNameClaimed   = errors.New("nickname is claimed by another player")
+	errAlreadyJoined = errors.New("already joined")
+)
+
+// identities holds the token hash of each claimed nickname, saved as a JSON
+// object to file if one is given, and the client using each nickname. Safe for
+// use by concurrent clients
+type identities struct {
+	file   string
+	lock   sync.Mutex
+	tokens map[string]string  // hex SHA-256 of the token by nickname
+	online map[string]*client // connected client by nickname
+}
+
+func newIdentities(file string) *identities {
+	return &identities{
+		file:   file,
+		tokens: make(map[string]string),
+		online: make(map[string]*client),
+	}
+}
+
+// load reads the nicknames claimed in the file, if it exists
+func (ids *identities) load() error {
+	if ids.file == "" {
+		return nil
+	}
+	content, err := ioutil.ReadFile(ids.file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	ids.lock.Lock()
+	defer ids.lock.Unlock()
+	return json.Unmarshal(content, &ids.tokens)
+}
+
+// claim gives c the nickname if token matches the one issued for it, issuing
+// a new token if the nickname is unclaimed. Returns the new token, or "" if
+// the nickname was already claimed
+func (ids *identities) claim(c *client, name, token string) (string, error) {
+	ids.lock.Lock()
+	defer ids.lock.Unlock()
+	if other, ok := ids.online[name]; ok && other != c {
+		return "", errNameInUse
+	}
+	issued := ""
+	if hash, ok := ids.tokens[name]; ok {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(hashToken(token))) != 1 {
+			return "", errNameClaimed
+		}
+	} else {
+		issued = newToken()
+		ids.tokens[name] = hashToken(issued)
+		if err := ids.save(); err != nil {
+			delete(ids.tokens, name)
+			return "", err
+		}
+	}
+	ids.online[name] = c
+	return issued, nil
+}
+
+// release frees the nickname of a client which has disconnected
+func (ids *identities) release(c *client) {
+	ids.lock.Lock()
+	defer ids.lock.Unlock()
+	for name, other := range ids.online {
+		if other == c {
+			delete(ids.online, name)
+		}
+	}
+}
+
+// save writes the claimed nicknames to file. ids.lock must be held
+func (ids *identities) save() error {
+	if ids.file == "" {
+		return nil
+	}
+	content, err := json.MarshalIndent(ids.tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(ids.file, content, 0600)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// join identifies c by the nickname in m, then takes over the slot of a
+// dropped player of the same nickname if there is one
+func (h *Hub) join(ctx context.Context, c *client, m parse.Join) {
+	if c.name != "" {
+		c.send(parse.ErrorJSON(parse.NameTaken, errAlreadyJoined.Error()+" as "+c.name))
+		return
+	}
+	token, err := h.identities.claim(c, m.Name, m.Token)
+	if err != nil {
+		log.Printf("hub: client refused nickname %q: %s", m.Name, err.Error())
+		c.send(parse.ErrorJSON(parse.NameTaken, err.Error()))
+		return
+	}
+	c.name = m.Name
+	c.send(parse.JoinedJSON(m.Name, token))
+	if h.resume(ctx, c) {
+		return
+	}
+	if c.waiting() {
+		// matched by the rating of the nickname from now on
+		c.send(parse.QueuedJSON(c.queue.Name, h.ratings.get(c.name)))
+	}
+	if c.room != nil {
+		c.room.sendInfo()
+	}
+}
@@ -0,0 +1,104 @@
+package websocket
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/he-lium/sokoban/parse"
+)
+
+func TestJoin(t *testing.T) {
+	s := newRoomServer(t)
+	ann, _ := joinAs(s, "ann")
+	ann.expect("queued")
+
+	tables := []struct {
+		c    *testClient
+		msg  string
+		code string
+	}{
+		{ann, `{"type":"join","name":"ann"}`, parse.NameTaken},
+		{ann, `{"type":"join","name":"bob"}`, parse.NameTaken},
+		{s.dial("/ws"), `{"type":"join","name":"ann"}`, parse.NameTaken},
+		{s.dial("/ws"), `{"type":"join","name":""}`, parse.BadName},
+		{s.dial("/ws"), `{"type":"join","name":"ann "}`, parse.BadName},
+	}
+	for _, table := range tables {
+		table.c.send(table.msg)
+		table.c.expectError(table.code)
+	}
+
+	// players are named in the game they join
+	bob, _ := joinAs(s, "bob")
+	ann.send(`{"type":"create_room","min_players":2,"max_players":2}`)
+	r := expectRoom(t, ann, 1, 0)
+	bob.send(`{"type":"join_room","code":"` + r.Code + `"}`)
+	for _, c := range []*testClient{ann, bob} {
+		var init parse.GameInit
+		c.expect("game_init").decode(t, &init)
+		if !reflect.DeepEqual(init.Names, []string{"ann", "bob"}) {
+			t.Errorf("got names %q in game_init, expected ann and bob", init.Names)
+		}
+	}
+}
+
+func TestJoinToken(t *testing.T) {
+	s := newRoomServer(t)
+	ann, token := joinAs(s, "ann")
+
+	// the nickname is released when its client disconnects, but stays claimed
+	ann.conn.Close()
+	released(s, "ann")
+	tables := []struct {
+		token string
+		code  string // expected error code, empty if joined
+	}{
+		{"", parse.NameTaken},
+		{"wrong", parse.NameTaken},
+		{token + "0", parse.NameTaken},
+		{token, ""},
+	}
+	for _, table := range tables {
+		c := s.dial("/ws")
+		c.send(`{"type":"join","name":"ann","token":"` + table.token + `"}`)
+		if table.code != "" {
+			c.expectError(table.code)
+			continue
+		}
+		var joined parse.Joined
+		c.expect("joined").decode(t, &joined)
+		if joined.Name != "ann" || joined.Token != "" {
+			t.Errorf("joined as %+v, expected ann without a new token", joined)
+		}
+	}
+}
+
+func TestIdentitiesFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "auth.json")
+	ids := newIdentities(file)
+	if err := ids.load(); err != nil {
+		t.Fatalf("error loading missing file: %s", err.Error())
+	}
+	ann := &client{}
+	token, err := ids.claim(ann, "ann", "")
+	if err != nil || token == "" {
+		t.Fatalf("claiming ann gave token %q, error %v", token, err)
+	}
+	ids.release(ann)
+
+	// tokens are checked against the hashes saved to file
+	ids = newIdentities(file)
+	if err := ids.load(); err != nil {
+		t.Fatalf("error loading %s: %s", file, err.Error())
+	}
+	if _, err := ids.claim(&client{}, "ann", "wrong"); err != errNameClaimed {
+		t.Errorf("claiming ann with the wrong token gave error %v, expected %v", err, errNameClaimed)
+	}
+	if issued, err := ids.claim(ann, "ann", token); err != nil || issued != "" {
+		t.Errorf("claiming ann with its token gave token %q, error %v", issued, err)
+	}
+	if _, err := ids.claim(&client{}, "ann", token); err != errNameInUse {
+		t.Errorf("claiming ann while in use gave error %v, expected %v", err, errNameInUse)
+	}
+}
@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/he-lium/sokoban/leaderboard"
+	"github.com/he-lium/sokoban/parse"
+)
+
+// defaultTop is the number of players listed on a leaderboard unless the
+// request asks for more
+const defaultTop = 10
+
+// serveLeaderboards serves the levels with leaderboards at /leaderboard, and
+// the leaderboard of each level at /leaderboard/<level hash>. The "by" query
+// parameter chooses the ranking: "time" (default), "moves" or "pushes"; "n"
+// sets the number of players listed, 0 for every player
+func serveLeaderboards(s leaderboard.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		level := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/leaderboard"), "/")
+		if level == "" {
+			levels, err := s.Levels()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(parse.LeaderboardLevelsJSON(levels))
+			return
+		}
+
+		by := leaderboard.ByTime
+		if name := r.URL.Query().Get("by"); name != "" {
+			var ok bool
+			if by, ok = leaderboard.StrToRanking(name); !ok {
+				http.Error(w, "unknown ranking "+name, http.StatusBadRequest)
+				return
+			}
+		}
+		n := defaultTop
+		if count := r.URL.Query().Get("n"); count != "" {
+			var err error
+			if n, err = strconv.Atoi(count); err != nil {
+				http.Error(w, "bad number of players "+count, http.StatusBadRequest)
+				return
+			}
+		}
+
+		records, err := s.Records(level)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(records) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(parse.LeaderboardJSON(level, by, leaderboard.Top(records, by, n)))
+	}
+}
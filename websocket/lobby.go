@@ -0,0 +1,217 @@
+package websocket
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/parse"
+)
+
+// The lobby lists the rooms waiting to start and the games in progress. It is
+// served as JSON over HTTP, and pushed to websocket watchers on every change
+
+// lobby holds the listing of a Hub. Safe for use by the hub and its games
+type lobby struct {
+	lock     sync.Mutex
+	rooms    []parse.LobbyRoom
+	games    map[int]*parse.LobbyGame
+	watchers map[*client]bool
+	snapshot []byte // JSON listing last sent to watchers
+}
+
+func newLobby() *lobby {
+	l := &lobby{
+		games:    make(map[int]*parse.LobbyGame),
+		watchers: make(map[*client]bool),
+	}
+	l.snapshot = parse.LobbyJSON(nil, nil)
+	return l
+}
+
+// setRooms replaces the list of waiting rooms
+func (l *lobby) setRooms(rooms []parse.LobbyRoom) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.rooms = rooms
+	l.publish()
+}
+
+// addGame lists a game which has just started
+func (l *lobby) addGame(g parse.LobbyGame) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.games[g.ID] = &g
+	l.publish()
+}
+
+// updateGame applies f to the listing of a game in progress
+func (l *lobby) updateGame(id int, f func(g *parse.LobbyGame)) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if g, ok := l.games[id]; ok {
+		f(g)
+		l.publish()
+	}
+}
+
+// removeGame unlists a finished game
+func (l *lobby) removeGame(id int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.games, id)
+	l.publish()
+}
+
+// publish regenerates the listing, sending it to watchers if changed.
+// lock must be held
+func (l *lobby) publish() {
+	games := make([]parse.LobbyGame, 0, len(l.games))
+	for _, g := range l.games {
+		games = append(games, *g)
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].ID < games[j].ID })
+
+	snapshot := parse.LobbyJSON(l.rooms, games)
+	if bytes.Equal(snapshot, l.snapshot) {
+		return
+	}
+	l.snapshot = snapshot
+	for c := range l.watchers {
+		select {
+		case c.sendMsg <- snapshot:
+		default:
+			log.Printf("lobby: watcher %p unresponsive", c)
+			delete(l.watchers, c)
+			close(c.sendMsg)
+		}
+	}
+}
+
+// watch sends the listing to c now and whenever it changes
+func (l *lobby) watch(c *client) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.watchers[c] = true
+	c.sendMsg <- l.snapshot
+}
+
+// unwatch stops sending the listing to c
+func (l *lobby) unwatch(c *client) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.watchers[c] {
+		delete(l.watchers, c)
+		close(c.sendMsg)
+	}
+}
+
+// closeWatchers disconnects every watcher
+func (l *lobby) closeWatchers() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for c := range l.watchers {
+		delete(l.watchers, c)
+		close(c.sendMsg)
+	}
+}
+
+// ServeHTTP serves the current listing as JSON
+func (l *lobby) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.lock.Lock()
+	snapshot := l.snapshot
+	l.lock.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(snapshot)
+}
+
+// updateLobby lists the public queue and private rooms of the hub
+func (h *Hub) updateLobby() {
+	rooms := make([]parse.LobbyRoom, 0, len(h.rooms)+len(h.queues))
+	for _, q := range h.queues {
+		rooms = append(rooms, parse.LobbyRoom{
+			Name:       q.Name,
+			Players:    len(q.waiting),
+			MinPlayers: h.cfg.MinPlayers,
+			MaxPlayers: h.cfg.RoomSize,
+			Level:      q.Level,
+		})
+	}
+	for _, r := range h.rooms {
+		rooms = append(rooms, parse.LobbyRoom{
+			Private:    true,
+			Players:    len(r.players),
+			MinPlayers: r.minPlayers,
+			MaxPlayers: r.maxPlayers,
+			Level:      r.queue.Level,
+		})
+	}
+	h.lobby.setRooms(rooms)
+}
+
+// lobbyObserver keeps the listing of game id up to date as players finish
+func (h *Hub) lobbyObserver(id int) sokoban.Observer {
+	return sokoban.ObserverFunc(func(e sokoban.Event) {
+		switch e := e.(type) {
+		case sokoban.GameStarted:
+			// spectators are sent the starting board, which is now known
+			h.lobby.updateGame(id, func(g *parse.LobbyGame) {
+				g.BoardID = e.Board.ID
+				g.Spectatable = true
+			})
+		case sokoban.PlayerWon, sokoban.PlayerLeft, sokoban.PlayerTimedOut, sokoban.PlayerLimitReached:
+			h.lobby.updateGame(id, func(g *parse.LobbyGame) {
+				g.Playing--
+			})
+		}
+	})
+}
+
+// serveLobbyWs connects a websocket client which watches the lobby
+func serveLobbyWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	c := &client{
+		sendMsg:  make(chan []byte, 5),
+		conn:     conn,
+		hub:      hub,
+		playerID: -1,
+	}
+	select {
+	case <-hub.done:
+		conn.Close()
+		return
+	default:
+	}
+	c.sendMsg <- parse.HelloJSON()
+	hub.lobby.watch(c)
+
+	go c.outgoing()
+	go c.watchLobby()
+}
+
+// watchLobby discards messages from a lobby watcher until it disconnects
+func (c *client) watchLobby() {
+	defer func() {
+		c.hub.lobby.unwatch(c)
+		c.conn.Close()
+	}()
+	c.conn.SetReadLimit(maxMsgSize)
+	c.conn.SetReadDeadline(time.Now().Add(c.hub.cfg.PongWait))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(c.hub.cfg.PongWait))
+		return nil
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
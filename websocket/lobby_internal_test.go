@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/parse"
+)
+
+// solution3 solves mock board 3
+var solution3 = []string{"up", "left", "left", "down", "down", "right"}
+
+// privateRooms returns the private rooms of the listing
+func privateRooms(l parse.Lobby) []parse.LobbyRoom {
+	var rooms []parse.LobbyRoom
+	for _, r := range l.Rooms {
+		if r.Private {
+			rooms = append(rooms, r)
+		}
+	}
+	return rooms
+}
+
+// expectLobby waits for the watcher to be sent a listing for which cond is
+// true, then checks that /lobby serves it too
+func expectLobby(t *testing.T, s *testServer, w *testClient, what string, cond func(l parse.Lobby) bool) {
+	t.Helper()
+	for {
+		var l parse.Lobby
+		w.expect("lobby").decode(t, &l)
+		if cond(l) {
+			break
+		}
+	}
+	resp, body := s.request(http.MethodGet, "/lobby", "", "")
+	var l parse.Lobby
+	if err := json.Unmarshal([]byte(body), &l); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /lobby got status %d with %s", resp.StatusCode, body)
+	}
+	if !cond(l) {
+		t.Fatalf("GET /lobby served %s, expected %s", body, what)
+	}
+}
+
+func TestLobby(t *testing.T) {
+	s := newRoomServer(t)
+	w := s.dial("/ws/lobby")
+	expectLobby(t, s, w, "only the public queue", func(l parse.Lobby) bool {
+		return len(l.Rooms) == 1 && l.Rooms[0].Name == "public" && len(l.Games) == 0
+	})
+
+	a, b := s.dial("/ws"), s.dial("/ws")
+	a.send(`{"type":"create_room","min_players":2,"max_players":2}`)
+	r := expectRoom(t, a, 1, 0)
+	expectLobby(t, s, w, "a private room of one player", func(l parse.Lobby) bool {
+		rooms := privateRooms(l)
+		return len(rooms) == 1 && rooms[0].Players == 1 && rooms[0].MaxPlayers == 2
+	})
+
+	// the room starts once full, becoming a game
+	b.send(`{"type":"join_room","code":"` + r.Code + `"}`)
+	expectGame(t, a, b)
+	expectLobby(t, s, w, "a spectatable game of two players", func(l parse.Lobby) bool {
+		return len(privateRooms(l)) == 0 && len(l.Games) == 1 &&
+			l.Games[0].Players == 2 && l.Games[0].Level == "mock3" && l.Games[0].Spectatable
+	})
+
+	// the game is unlisted once both players solve the board
+	for _, c := range []*testClient{a, b} {
+		for _, d := range solution3 {
+			c.send(`{"type":"move","direction":"` + d + `"}`)
+			c.expect("action_result")
+		}
+	}
+	a.expect("game_over")
+	expectLobby(t, s, w, "no games", func(l parse.Lobby) bool {
+		return len(l.Games) == 0
+	})
+}
+
+func TestLobbyLimits(t *testing.T) {
+	cfg := testConfig()
+	cfg.Limits = sokoban.Limits{MaxMoves: 2}
+	s := newTestServer(t, cfg)
+	w := s.dial("/ws/lobby")
+	clients, _ := s.startGame()
+	expectLobby(t, s, w, "a game of two players playing", func(l parse.Lobby) bool {
+		return len(l.Games) == 1 && l.Games[0].Playing == 2
+	})
+
+	// players who use up their moves are no longer playing
+	for _, d := range solution3[:2] {
+		clients[0].send(`{"type":"move","direction":"` + d + `"}`)
+		clients[0].expect("action_result")
+	}
+	expectLobby(t, s, w, "a game of one player playing", func(l parse.Lobby) bool {
+		return len(l.Games) == 1 && l.Games[0].Playing == 1
+	})
+	for _, d := range solution3[:2] {
+		clients[1].send(`{"type":"move","direction":"` + d + `"}`)
+		clients[1].expect("action_result")
+	}
+	clients[1].expect("game_over")
+	expectLobby(t, s, w, "no games", func(l parse.Lobby) bool {
+		return len(l.Games) == 0
+	})
+}
@@ -0,0 +1,194 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/he-lium/sokoban/parse"
+)
+
+// Matched players must each send "ready" within the ready timeout before their
+// game starts. Once everyone is ready the hub counts down the seconds and then
+// starts the game. Players who aren't ready in time are sent back to the public
+// queue, and the rest start without them if there are still enough players
+
+// match is a group of clients waiting for each other to be ready. owned by hub
+type match struct {
+	players    []*client
+	ready      []bool
+	minPlayers int         // fewest players the game may start with
+	queue      *queue      // queue whose levels the game is played on
+	deadline   time.Time   // when unready players are sent back to the queue
+	countdown  int         // seconds left to count down, -1 until all are ready
+	timer      *time.Timer // fires the ready timeout, then each second of countdown
+	gen        int         // incremented whenever timer is replaced
+}
+
+// startMatch starts the ready check for clients matched for a game, or starts
+// the game straight away if there is no ready check
+func (h *Hub) startMatch(ctx context.Context, clients []*client, minPlayers int, q *queue) {
+	if h.cfg.ReadyTimeout == 0 {
+		h.startNewGame(ctx, clients, q.Queue, nil)
+		return
+	}
+	m := &match{
+		players:    clients,
+		ready:      make([]bool, len(clients)),
+		minPlayers: minPlayers,
+		queue:      q,
+		deadline:   time.Now().Add(h.cfg.ReadyTimeout),
+		countdown:  -1,
+	}
+	h.matches[m] = true
+	for _, c := range clients {
+		c.match = m
+	}
+	h.setMatchTimer(m, h.cfg.ReadyTimeout)
+	log.Printf("matched %d players. waiting for them to be ready", len(clients))
+	m.sendInfo()
+}
+
+// setMatchTimer arranges for the hub to tick m after d
+func (h *Hub) setMatchTimer(m *match, d time.Duration) {
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.gen++
+	gen := m.gen
+	m.timer = h.after(d, func(ctx context.Context) {
+		if h.matches[m] && m.gen == gen {
+			h.tickMatch(ctx, m)
+		}
+	})
+}
+
+// ready marks c as ready, starting the countdown once everyone is ready
+func (h *Hub) ready(ctx context.Context, c *client) {
+	m := c.match
+	if m.countdown >= 0 {
+		return
+	}
+	m.ready[m.index(c)] = true
+	m.sendInfo()
+	if m.allReady() {
+		h.beginCountdown(ctx, m)
+	}
+}
+
+// beginCountdown starts counting down to the start of the game
+func (h *Hub) beginCountdown(ctx context.Context, m *match) {
+	m.countdown = h.cfg.Countdown
+	h.tickMatch(ctx, m)
+}
+
+// tickMatch sends the next second of the countdown, starting the game once it
+// has finished, or sends back unready players once the ready timeout runs out
+func (h *Hub) tickMatch(ctx context.Context, m *match) {
+	switch {
+	case m.countdown < 0:
+		h.dropUnready(ctx, m)
+	case m.countdown == 0:
+		h.endMatch(m)
+		log.Printf("match of %d players starting game", len(m.players))
+		h.startNewGame(ctx, m.players, m.queue.Queue, nil)
+	default:
+		for _, c := range m.players {
+			c.send(parse.CountdownJSON(m.countdown))
+		}
+		m.countdown--
+		h.setMatchTimer(m, time.Second)
+	}
+}
+
+// dropUnready sends players who weren't ready in time back to the queue
+func (h *Hub) dropUnready(ctx context.Context, m *match) {
+	for i := len(m.players) - 1; i >= 0; i-- {
+		if !m.ready[i] {
+			c := m.players[i]
+			m.remove(c)
+			h.requeue(c, "not ready in time")
+		}
+	}
+	h.checkMatch(ctx, m)
+	h.checkQueues(ctx)
+}
+
+// leaveMatch removes a client which quit while waiting for its match
+func (h *Hub) leaveMatch(ctx context.Context, c *client) {
+	m := c.match
+	m.remove(c)
+	h.checkMatch(ctx, m)
+	h.checkQueues(ctx)
+}
+
+// checkMatch cancels a match which has lost too many players, sending the
+// rest back to the queue, or starts the countdown once the rest are ready
+func (h *Hub) checkMatch(ctx context.Context, m *match) {
+	switch {
+	case len(m.players) < m.minPlayers || m.humans() == 0:
+		h.endMatch(m)
+		for _, c := range m.players {
+			h.requeue(c, "not enough players were ready")
+		}
+	case m.countdown < 0 && m.allReady():
+		h.beginCountdown(ctx, m)
+	case m.countdown < 0:
+		m.sendInfo()
+	}
+}
+
+// endMatch stops the ready check of m
+func (h *Hub) endMatch(m *match) {
+	m.timer.Stop()
+	delete(h.matches, m)
+	for _, c := range m.players {
+		c.match = nil
+	}
+}
+
+// requeue sends a matched client back to the public queue. Bots are removed
+// instead, as they only play with the players they were added for
+func (h *Hub) requeue(c *client, reason string) {
+	c.match = nil
+	if c.bot {
+		close(c.sendMsg)
+		return
+	}
+	c.send(parse.RequeuedJSON(reason))
+	c.queue.waiting[c] = true
+}
+
+func (m *match) index(c *client) int {
+	for i, p := range m.players {
+		if p == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// remove takes c out of the match
+func (m *match) remove(c *client) {
+	i := m.index(c)
+	m.players = append(m.players[:i], m.players[i+1:]...)
+	m.ready = append(m.ready[:i], m.ready[i+1:]...)
+	c.match = nil
+}
+
+func (m *match) allReady() bool {
+	for _, r := range m.ready {
+		if !r {
+			return false
+		}
+	}
+	return true
+}
+
+// sendInfo tells each player who is ready
+func (m *match) sendInfo() {
+	left := time.Until(m.deadline)
+	for i, c := range m.players {
+		c.send(parse.MatchJSON(i, m.ready, left))
+	}
+}
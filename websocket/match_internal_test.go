@@ -0,0 +1,110 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/he-lium/sokoban/parse"
+)
+
+// newMatchServer starts a server which matches players into games of size,
+// of which minPlayers must be ready in time, with a one second countdown
+func newMatchServer(t *testing.T, size, minPlayers int) *testServer {
+	cfg := testConfig()
+	cfg.RoomSize = size
+	cfg.MinPlayers = minPlayers
+	cfg.ReadyTimeout = 300 * time.Millisecond
+	cfg.Countdown = 1
+	return newTestServer(t, cfg)
+}
+
+// expectMatch waits for c to be told who is ready, failing unless the players
+// marked ready are as given. Returns the index of c in the match
+func expectMatch(t *testing.T, c *testClient, ready ...bool) int {
+	t.Helper()
+	var m parse.Match
+	c.expect("match").decode(t, &m)
+	if len(m.Ready) != len(ready) {
+		t.Fatalf("got match %+v, expected %d players", m, len(ready))
+	}
+	for i := range ready {
+		if m.Ready[i] != ready[i] {
+			t.Fatalf("got match %+v, expected ready %v", m, ready)
+		}
+	}
+	return m.Me
+}
+
+func TestMatchReady(t *testing.T) {
+	s := newMatchServer(t, 2, 2)
+	a, b := s.dial("/ws"), s.dial("/ws")
+	me := expectMatch(t, a, false, false)
+	if expectMatch(t, b, false, false) != 1-me {
+		t.Fatal("both players have the same index")
+	}
+
+	ready := []bool{false, false}
+	ready[me] = true
+	a.send(`{"type":"ready"}`)
+	expectMatch(t, a, ready...)
+	expectMatch(t, b, ready...)
+	b.send(`{"type":"ready"}`)
+	expectMatch(t, a, true, true)
+	expectMatch(t, b, true, true)
+
+	for _, c := range []*testClient{a, b} {
+		var countdown parse.Countdown
+		c.expect("countdown").decode(t, &countdown)
+		if countdown.Seconds != 1 {
+			t.Errorf("counted down from %d, expected 1", countdown.Seconds)
+		}
+	}
+	// the ready timeout doesn't apply once everyone is ready
+	expectGame(t, a, b)
+	a.expectNone("requeued", 100*time.Millisecond)
+}
+
+func TestMatchTimeout(t *testing.T) {
+	s := newMatchServer(t, 3, 3)
+	clients := []*testClient{s.dial("/ws"), s.dial("/ws"), s.dial("/ws")}
+	for _, c := range clients {
+		expectMatch(t, c, false, false, false)
+	}
+
+	// too few players are ready in time: the ready players are sent back to
+	// the queue for not having enough others, and the rest for not being ready
+	clients[0].send(`{"type":"ready"}`)
+	clients[1].send(`{"type":"ready"}`)
+	for i, c := range clients {
+		m := c.expect("requeued")
+		reason := "not enough players were ready"
+		if i == 2 {
+			reason = "not ready in time"
+		}
+		if m.Reason != reason {
+			t.Errorf("player %d requeued because %q, expected %q", i, m.Reason, reason)
+		}
+	}
+
+	// back in the queue, they are matched again
+	for _, c := range clients {
+		c.expect("match")
+	}
+}
+
+func TestMatchWithoutUnready(t *testing.T) {
+	s := newMatchServer(t, 3, 2)
+	clients := []*testClient{s.dial("/ws"), s.dial("/ws"), s.dial("/ws")}
+	for _, c := range clients {
+		expectMatch(t, c, false, false, false)
+	}
+
+	// enough players are ready in time to start without the last
+	clients[0].send(`{"type":"ready"}`)
+	clients[1].send(`{"type":"ready"}`)
+	if m := clients[2].expect("requeued"); m.Reason != "not ready in time" {
+		t.Errorf("requeued because %q, expected not being ready in time", m.Reason)
+	}
+	expectGame(t, clients[0], clients[1])
+	clients[2].expectNone("game_init", 100*time.Millisecond)
+}
@@ -0,0 +1,80 @@
+package websocket
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/metrics"
+)
+
+// The server's metrics are served at /metrics in the Prometheus text format.
+// Counters only go up; the scraper works out rates from them, e.g. actions
+// per second of each type with rate(sokoban_actions_total[1m])
+
+// pingBuckets are the upper bounds in seconds of the ping round-trip buckets
+var pingBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// serverMetrics holds the metrics of a Hub and the games it runs
+type serverMetrics struct {
+	registry  *metrics.Registry
+	connected *metrics.Gauge      // players and spectators with an open connection
+	waiting   *metrics.Gauge      // set by the hub after each event
+	finished  *metrics.Counter    // games which have finished
+	actions   *metrics.CounterVec // actions applied, by type
+	rejected  *metrics.CounterVec // actions refused, by error code
+	dropped   *metrics.Counter    // players disconnected by sendTo for being too slow
+	ping      *metrics.Histogram  // round-trip time of pings, in seconds
+}
+
+func newServerMetrics(h *Hub) *serverMetrics {
+	r := metrics.NewRegistry()
+	m := &serverMetrics{
+		registry: r,
+		connected: r.NewGauge("sokoban_connected_clients",
+			"Websocket clients connected to play or spectate."),
+		waiting: r.NewGauge("sokoban_waiting_clients",
+			"Clients waiting in public queues, private rooms and matches, including bots."),
+	}
+	r.NewGaugeFunc("sokoban_active_games", "Games in progress.", func() float64 {
+		h.gameLock.Lock()
+		defer h.gameLock.Unlock()
+		return float64(len(h.running))
+	})
+	m.finished = r.NewCounter("sokoban_games_finished_total", "Games which have finished.")
+	m.actions = r.NewCounterVec("sokoban_actions_total",
+		"Actions processed by games, by type.", "type")
+	m.rejected = r.NewCounterVec("sokoban_rejected_actions_total",
+		"Player input refused without being attempted, by error code.", "reason")
+	m.dropped = r.NewCounter("sokoban_dropped_clients_total",
+		"Players disconnected for not keeping up with the messages of their game.")
+	m.ping = r.NewHistogram("sokoban_ping_rtt_seconds",
+		"Round-trip time of websocket pings.", pingBuckets)
+	return m
+}
+
+// observer counts the actions of a game by type
+func (m *serverMetrics) observer() sokoban.Observer {
+	return sokoban.ObserverFunc(func(e sokoban.Event) {
+		if e, ok := e.(sokoban.ActionApplied); ok {
+			m.actions.With(sokoban.ActionTypeToStr(e.Action.Type)).Inc()
+		}
+	})
+}
+
+// pingData is the payload of a ping sent now, which the peer echoes in its pong
+func pingData() []byte {
+	return []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+}
+
+// pong records the round-trip time of the ping echoed in appData, ignoring
+// unsolicited pongs
+func (m *serverMetrics) pong(appData string) {
+	sent, err := strconv.ParseInt(appData, 10, 64)
+	if err != nil {
+		return
+	}
+	if rtt := time.Since(time.Unix(0, sent)); rtt >= 0 {
+		m.ping.Observe(rtt.Seconds())
+	}
+}
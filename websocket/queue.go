@@ -0,0 +1,159 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/parse"
+)
+
+// Clients wait to play in public queues, each with its own levels, e.g. a
+// level pack or a band of difficulty. New clients wait in the first queue and
+// may move to another with "join_queue". Within a queue, the hub matches
+// players whose ratings are closest together
+
+// Queue is a public queue of players waiting to play the same levels
+type Queue struct {
+	Name  string             // name clients use to join the queue
+	Level string             // description of the levels shown in the lobby
+	Gen   sokoban.BoardMaker // generates the boards of the queue's games
+}
+
+// queue holds the clients waiting in a Queue. owned by hub
+type queue struct {
+	Queue
+	waiting  map[*client]bool
+	timer    *time.Timer // fires once the lobby wait runs out, if running
+	timerGen int         // incremented whenever timer is stopped
+	botTimer *time.Timer // fires once a player has waited long enough for bots
+	botGen   int         // incremented whenever botTimer is stopped
+}
+
+func newQueue(q Queue) *queue {
+	return &queue{Queue: q, waiting: make(map[*client]bool)}
+}
+
+// findQueue returns the queue with the given name, or nil if there is none
+func (h *Hub) findQueue(name string) *queue {
+	for _, q := range h.queues {
+		if q.Name == name {
+			return q
+		}
+	}
+	return nil
+}
+
+// enqueue adds c to the public queue q
+func (h *Hub) enqueue(ctx context.Context, c *client, q *queue) {
+	c.queue = q
+	q.waiting[c] = true
+	log.Printf("client joined queue %s. now %d players", q.Name, len(q.waiting))
+	c.send(parse.QueuedJSON(q.Name, h.ratings.get(c.name)))
+	h.checkStart(ctx, q)
+}
+
+// joinQueue moves c from its public queue to the queue with the given name
+func (h *Hub) joinQueue(ctx context.Context, c *client, m parse.JoinQueue) {
+	q := h.findQueue(m.Queue)
+	switch {
+	case q == nil:
+		c.send(parse.ErrorJSON(parse.NoSuchQueue, "no queue named "+m.Queue))
+		return
+	case c.room != nil:
+		c.send(parse.ErrorJSON(parse.RoomRefused, "leave the room before joining a queue"))
+		return
+	}
+	h.removeWaiting(ctx, c)
+	h.enqueue(ctx, c, q)
+}
+
+// removeWaiting takes a client out of its public queue
+func (h *Hub) removeWaiting(ctx context.Context, c *client) {
+	q := c.queue
+	delete(q.waiting, c)
+	log.Printf("client left queue %s. now %d players", q.Name, len(q.waiting))
+	h.checkStart(ctx, q)
+}
+
+// waiting determines whether c is waiting in a public queue
+func (c *client) waiting() bool {
+	return c.queue != nil && c.queue.waiting[c]
+}
+
+// checkStart starts a game once the queue can fill a room, or starts the lobby
+// timer once there are enough players to start a game without a full room.
+// Until then, bots are added once players have waited long enough
+func (h *Hub) checkStart(ctx context.Context, q *queue) {
+	defer h.checkBots(q)
+	switch {
+	case len(q.waiting) >= h.cfg.RoomSize:
+		// enough people have joined; assign Controller and start game
+		q.stopTimer()
+		h.startPublicGame(ctx, q)
+	case len(q.waiting) < h.cfg.MinPlayers:
+		q.stopTimer()
+	case q.timer == nil:
+		gen := q.timerGen
+		q.timer = h.after(h.cfg.LobbyWait, func(ctx context.Context) {
+			if q.timerGen == gen {
+				// waited long enough for a full room
+				q.stopTimer()
+				h.startPublicGame(ctx, q)
+			}
+		})
+	}
+}
+
+// checkQueues checks whether each queue can start a game
+func (h *Hub) checkQueues(ctx context.Context) {
+	for _, q := range h.queues {
+		h.checkStart(ctx, q)
+	}
+}
+
+// stopTimer stops the lobby timer of the queue
+func (q *queue) stopTimer() {
+	if q.timer != nil {
+		q.timer.Stop()
+		q.timer = nil
+		q.timerGen++
+	}
+}
+
+// startPublicGame matches up to a full room of clients from the queue, picking
+// the players whose ratings are closest together
+func (h *Hub) startPublicGame(ctx context.Context, q *queue) {
+	clients := make([]*client, 0, len(q.waiting))
+	for c := range q.waiting {
+		clients = append(clients, c)
+	}
+	rating := make(map[*client]float64, len(clients))
+	for _, c := range clients {
+		rating[c] = h.ratings.get(c.name)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return rating[clients[i]] < rating[clients[j]]
+	})
+
+	n := h.cfg.RoomSize
+	if len(clients) < n {
+		n = len(clients)
+	}
+	best := 0
+	for i := 1; i+n <= len(clients); i++ {
+		spread := rating[clients[i+n-1]] - rating[clients[i]]
+		if spread < rating[clients[best+n-1]]-rating[clients[best]] {
+			best = i
+		}
+	}
+	matched := clients[best : best+n]
+	for _, c := range matched {
+		delete(q.waiting, c)
+	}
+	log.Printf("queue %s matched %d players. now %d players", q.Name, n, len(q.waiting))
+	h.startMatch(ctx, matched, h.cfg.MinPlayers, q)
+	h.checkStart(ctx, q)
+}
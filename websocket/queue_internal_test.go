@@ -0,0 +1,163 @@
+package websocket
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/he-lium/sokoban"
+	"github.com/he-lium/sokoban/mock"
+	"github.com/he-lium/sokoban/parse"
+)
+
+// newQueueServer starts a server whose public queue starts games of 2 to 3
+// players, waiting lobbyWait for a full room
+func newQueueServer(t *testing.T, lobbyWait time.Duration) *testServer {
+	cfg := testConfig()
+	cfg.RoomSize = 3
+	cfg.MinPlayers = 2
+	cfg.LobbyWait = lobbyWait
+	return newTestServer(t, cfg)
+}
+
+func TestQueueStartsWhenFull(t *testing.T) {
+	s := newQueueServer(t, time.Hour)
+	a, b, c := s.dial("/ws"), s.dial("/ws"), s.dial("/ws")
+	expectGame(t, a, b, c)
+}
+
+func TestQueueStartsAfterLobbyWait(t *testing.T) {
+	const wait = 300 * time.Millisecond
+	s := newQueueServer(t, wait)
+	a := s.dial("/ws")
+	a.expect("queued")
+	b := s.dial("/ws")
+	b.expect("queued")
+	queued := time.Now()
+
+	expectGame(t, a, b)
+	if waited := time.Since(queued); waited < wait*9/10 {
+		t.Errorf("game started after %v, expected the lobby wait of %v", waited, wait)
+	}
+}
+
+func TestQueueWaitsForMinPlayers(t *testing.T) {
+	s := newQueueServer(t, 50*time.Millisecond)
+	a := s.dial("/ws")
+	a.expect("queued")
+	a.expectNone("game_init", 300*time.Millisecond)
+
+	// the lobby wait starts once there are enough players
+	b := s.dial("/ws")
+	expectGame(t, a, b)
+}
+
+// levelBand writes the boards of the mock BoardMakers to a level directory,
+// returning the levels with no boxes
+func levelBand(t *testing.T) *parse.LevelDir {
+	dir := t.TempDir()
+	makers := []sokoban.BoardMaker{mock.BoardMaker1{}, mock.BoardMaker2{}, mock.BoardMaker3{}}
+	for i, m := range makers {
+		b, _ := m.GenBoard()
+		json, err := parse.BoardToJSON(b)
+		if err != nil {
+			t.Fatalf("error writing to JSON: %s", err.Error())
+		}
+		name := filepath.Join(dir, string(rune('a'+i))+".json")
+		if err := ioutil.WriteFile(name, json, 0644); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	levels, err := parse.LoadLevelDir(dir)
+	if err != nil {
+		t.Fatalf("error loading levels: %s", err.Error())
+	}
+	band, err := levels.Band(0, 0)
+	if err != nil {
+		t.Fatalf("error making band: %s", err.Error())
+	}
+	return band
+}
+
+func TestJoinQueue(t *testing.T) {
+	cfg := testConfig()
+	cfg.Queues = []Queue{
+		{"pack", "mock2", mock.BoardMaker2{}},
+		{"easy", "levels (0-0 boxes)", levelBand(t)},
+	}
+	s := newTestServer(t, cfg)
+
+	tables := []struct {
+		queue string
+		board int // ID of the first board of the queue
+	}{
+		{"pack", 1},
+		{"easy", 0},
+	}
+	for _, table := range tables {
+		var clients []*testClient
+		for i := 0; i < 2; i++ {
+			c := s.dial("/ws")
+			clients = append(clients, c)
+			c.expect("queued")
+			c.send(`{"type":"join_queue","queue":"` + table.queue + `"}`)
+			var queued parse.Queued
+			c.expect("queued").decode(t, &queued)
+			if queued.Queue != table.queue {
+				t.Fatalf("queued in %s, expected %s", queued.Queue, table.queue)
+			}
+		}
+		for _, c := range clients {
+			var init parse.GameInit
+			c.expect("game_init").decode(t, &init)
+			if init.GameBoard.ID != table.board {
+				t.Errorf("queue %s started board %d, expected %d", table.queue, init.GameBoard.ID, table.board)
+			}
+		}
+	}
+
+	c := s.dial("/ws")
+	c.send(`{"type":"join_queue","queue":"hard"}`)
+	c.expectError(parse.NoSuchQueue)
+}
+
+func TestQueueMatchesRatings(t *testing.T) {
+	cfg := testConfig()
+	cfg.RoomSize = MaxRoomSize
+	cfg.MinPlayers = MaxRoomSize
+	s := newTestServer(t, cfg)
+	ratings := map[string]float64{"ann": 1000, "bob": 1900, "cat": 1050, "dan": 2000}
+	s.hub.ratings.lock.Lock()
+	for name, rating := range ratings {
+		s.hub.ratings.byName[name] = rating
+	}
+	s.hub.ratings.lock.Unlock()
+
+	clients := make(map[string]*testClient)
+	for _, name := range []string{"ann", "bob", "cat", "dan"} {
+		c, _ := joinAs(s, name)
+		var queued parse.Queued
+		c.expect("queued").decode(t, &queued)
+		if queued.Rating != int(ratings[name]) {
+			t.Errorf("%s queued with rating %d, expected %v", name, queued.Rating, ratings[name])
+		}
+		clients[name] = c
+	}
+
+	// in games of two, the closest ratings play together
+	s.hub.call(func(ctx context.Context) {
+		s.hub.cfg.RoomSize = 2
+		s.hub.cfg.MinPlayers = 2
+		s.hub.checkStart(ctx, s.hub.queues[0])
+	})
+	opponents := map[string]string{"ann": "cat", "cat": "ann", "bob": "dan", "dan": "bob"}
+	for name, opponent := range opponents {
+		var init parse.GameInit
+		clients[name].expect("game_init").decode(t, &init)
+		if init.NPlayers != 2 || init.Names[1-init.Me] != opponent {
+			t.Errorf("%s is playing %q, expected %s", name, init.Names, opponent)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package websocket
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/he-lium/sokoban"
+)
+
+// ratings holds the Elo rating of each nickname, saved as a JSON object to
+// file if one is given. Safe for use by concurrent games
+type ratings struct {
+	file   string
+	lock   sync.Mutex
+	byName map[string]float64
+}
+
+func newRatings(file string) *ratings {
+	return &ratings{file: file, byName: make(map[string]float64)}
+}
+
+// load reads the ratings saved to the file, if it exists
+func (r *ratings) load() error {
+	if r.file == "" {
+		return nil
+	}
+	content, err := ioutil.ReadFile(r.file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return json.Unmarshal(content, &r.byName)
+}
+
+// get returns the rating of the player with the given nickname
+func (r *ratings) get(name string) float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if rating, ok := r.byName[name]; ok && name != "" {
+		return rating
+	}
+	return sokoban.DefaultRating
+}
+
+// update rates the players of a game who have a nickname by its result, as if
+// they had played without the players who have none, such as bots and ghosts.
+// A game with fewer than two players with a nickname changes no ratings
+func (r *ratings) update(names []string, result sokoban.Result) error {
+	var named []int
+	for i, name := range names {
+		if name != "" {
+			named = append(named, i)
+		}
+	}
+	if len(named) < 2 {
+		return nil
+	}
+	before := make([]float64, len(named))
+	played := sokoban.Result{Players: make([]sokoban.PlayerResult, len(named))}
+	for j, i := range named {
+		before[j] = r.get(names[i])
+		played.Players[j] = result.Players[i]
+	}
+	after := sokoban.Rate(before, played)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for j, i := range named {
+		r.byName[names[i]] = after[j]
+	}
+	if r.file == "" {
+		return nil
+	}
+	content, err := json.MarshalIndent(r.byName, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.file, content, 0644)
+}
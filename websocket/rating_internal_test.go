@@ -0,0 +1,41 @@
+package websocket
+
+import (
+	"math"
+	"testing"
+
+	"github.com/he-lium/sokoban"
+)
+
+func TestRatingsUpdate(t *testing.T) {
+	won := sokoban.PlayerResult{Status: sokoban.Finished, Rank: 1}
+	second := sokoban.PlayerResult{Status: sokoban.Finished, Rank: 2}
+	lost := sokoban.PlayerResult{Status: sokoban.Unfinished}
+
+	tables := []struct {
+		names   []string
+		players []sokoban.PlayerResult
+		ann     float64 // rating of ann after the game
+		bob     float64
+	}{
+		// equal players: the winner takes half of RatingK from the loser
+		{[]string{"ann", "bob"}, []sokoban.PlayerResult{won, lost}, 1516, 1484},
+		// bots, ghosts and players who haven't joined aren't rated against
+		{[]string{"ann", ""}, []sokoban.PlayerResult{lost, won}, 1500, 1500},
+		{[]string{"", "ann", "bob"}, []sokoban.PlayerResult{won, second, lost}, 1516, 1484},
+		{[]string{"bob", "", "ann"}, []sokoban.PlayerResult{lost, won, second}, 1516, 1484},
+	}
+	for _, table := range tables {
+		r := newRatings("")
+		if err := r.update(table.names, sokoban.Result{Players: table.players}); err != nil {
+			t.Fatalf("error updating ratings: %s", err.Error())
+		}
+		if math.Abs(r.get("ann")-table.ann) > 1e-9 || math.Abs(r.get("bob")-table.bob) > 1e-9 {
+			t.Errorf("%q rated ann %v and bob %v, expected %v and %v",
+				table.names, r.get("ann"), r.get("bob"), table.ann, table.bob)
+		}
+		if _, ok := r.byName[""]; ok {
+			t.Errorf("%q rated a player without a nickname", table.names)
+		}
+	}
+}
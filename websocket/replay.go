@@ -0,0 +1,42 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/he-lium/sokoban/replay"
+)
+
+// serveReplays lists the recorded games at /replays, and serves the replay of
+// each game for download at /replays/<id>
+func serveReplays(s replay.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/replays"), "/")
+		if id == "" {
+			infos, err := s.List()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			j, _ := json.Marshal(struct {
+				Replays []replay.Info `json:"replays"`
+			}{infos})
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(j)
+			return
+		}
+
+		content, err := s.Get(id)
+		if err == replay.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+id+`.json"`)
+		w.Write(content)
+	}
+}
@@ -0,0 +1,278 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/he-lium/sokoban/parse"
+)
+
+// Private rooms let players start a game together: the host creates a room,
+// shares its join code with the other players, and starts the game once
+// everyone has joined. The game also starts by itself once the room is full,
+// or once it has its minimum players and the lobby wait has run out
+
+// room is a private group of clients waiting to start a game. owned by hub
+type room struct {
+	code       string
+	players    []*client   // in order of joining; the first player is host
+	minPlayers int         // fewest players the game may start with
+	maxPlayers int         // most players; the game starts once reached
+	queue      *queue      // queue of the host, whose levels the game is played on
+	timer      *time.Timer // fires once the lobby wait runs out, if running
+	gen        int         // incremented whenever timer is stopped
+}
+
+// hubRequest is a message from a client who is not yet playing
+type hubRequest struct {
+	client *client
+	msg    parse.Message
+	reply  []byte // if set, an error to send back instead of acting
+}
+
+const (
+	codeLength = 5
+	codeChars  = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // no lookalikes e.g. 0 O 1 I
+)
+
+// handleRequest carries out a lobby action on behalf of a client
+func (h *Hub) handleRequest(ctx context.Context, req hubRequest) {
+	c := req.client
+	if c.room == nil && !c.waiting() && c.match == nil {
+		// game has already started
+		return
+	}
+	if req.reply != nil {
+		c.send(req.reply)
+		return
+	}
+	if c.match != nil {
+		switch req.msg.(type) {
+		case parse.Ready:
+			h.ready(ctx, c)
+		case parse.Chat, parse.Emote:
+			h.relayChat(c, req.msg)
+		default:
+			c.send(parse.ErrorJSON(parse.UnknownAction,
+				"cannot "+req.msg.MessageType()+" while waiting for players to be ready"))
+		}
+		return
+	}
+
+	switch m := req.msg.(type) {
+	case parse.Join:
+		h.join(ctx, c, m)
+	case parse.JoinQueue:
+		h.joinQueue(ctx, c, m)
+	case parse.RaceGhost:
+		if m.Solver {
+			h.raceSolver(ctx, c)
+		} else {
+			h.raceGhost(ctx, c, m.Replay)
+		}
+	case parse.CreateRoom:
+		h.createRoom(ctx, c, m.MinPlayers, m.MaxPlayers)
+	case parse.JoinRoom:
+		h.joinRoom(ctx, c, m.Code)
+	case parse.LeaveRoom:
+		if c.room != nil {
+			h.leaveRoom(c)
+			h.enqueue(ctx, c, c.queue)
+		}
+	case parse.Kick:
+		h.kick(ctx, c, m.Player)
+	case parse.StartRoom:
+		h.startRoom(ctx, c)
+	case parse.Chat, parse.Emote:
+		h.relayChat(c, req.msg)
+	default:
+		c.send(parse.ErrorJSON(parse.UnknownAction,
+			"cannot "+req.msg.MessageType()+" before the game starts"))
+	}
+}
+
+// createRoom moves c from the public queue into a new room as its host. Zero
+// player counts take the defaults of the public queue
+func (h *Hub) createRoom(ctx context.Context, c *client, minPlayers, maxPlayers int) {
+	if minPlayers == 0 {
+		minPlayers = h.cfg.MinPlayers
+	}
+	if maxPlayers == 0 {
+		maxPlayers = h.cfg.RoomSize
+	}
+	if minPlayers < 1 || minPlayers > maxPlayers || maxPlayers > MaxRoomSize {
+		c.send(parse.ErrorJSON(parse.RoomRefused, fmt.Sprintf(
+			"rooms need between 1 and %d players, with min players at most max", MaxRoomSize)))
+		return
+	}
+
+	if c.room != nil {
+		h.leaveRoom(c)
+	} else {
+		h.removeWaiting(ctx, c)
+	}
+
+	r := &room{
+		code:       h.newRoomCode(),
+		minPlayers: minPlayers,
+		maxPlayers: maxPlayers,
+		queue:      c.queue,
+	}
+	h.rooms[r.code] = r
+	log.Printf("room %s created for %d to %d players\n", r.code, minPlayers, maxPlayers)
+	h.addToRoom(ctx, r, c)
+}
+
+// joinRoom moves c into the room with the given join code
+func (h *Hub) joinRoom(ctx context.Context, c *client, code string) {
+	r, ok := h.rooms[code]
+	switch {
+	case !ok:
+		c.send(parse.ErrorJSON(parse.RoomRefused, "no room with code "+code))
+		return
+	case r == c.room:
+		return
+	case len(r.players) >= r.maxPlayers:
+		c.send(parse.ErrorJSON(parse.RoomRefused, "room "+code+" is full"))
+		return
+	}
+
+	if c.room != nil {
+		h.leaveRoom(c)
+	} else {
+		h.removeWaiting(ctx, c)
+	}
+	h.addToRoom(ctx, r, c)
+}
+
+func (h *Hub) addToRoom(ctx context.Context, r *room, c *client) {
+	r.players = append(r.players, c)
+	c.room = r
+	log.Printf("client joined room %s. now %d players", r.code, len(r.players))
+	r.sendInfo()
+	h.checkRoom(ctx, r)
+}
+
+// checkRoom starts the room's game once it is full, or starts its lobby timer
+// once it has enough players to start without being full
+func (h *Hub) checkRoom(ctx context.Context, r *room) {
+	switch {
+	case len(r.players) >= r.maxPlayers:
+		h.startRoomGame(ctx, r)
+	case len(r.players) < r.minPlayers:
+		r.stopTimer()
+	case r.timer == nil:
+		gen := r.gen
+		r.timer = h.after(h.cfg.LobbyWait, func(ctx context.Context) {
+			if h.rooms[r.code] == r && r.gen == gen {
+				log.Printf("room %s waited long enough for players", r.code)
+				h.startRoomGame(ctx, r)
+			}
+		})
+	}
+}
+
+// stopTimer stops the lobby timer of the room
+func (r *room) stopTimer() {
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+		r.gen++
+	}
+}
+
+// leaveRoom removes c from its room, handing over the host to the next
+// player and closing the room once empty
+func (h *Hub) leaveRoom(c *client) {
+	r := c.room
+	c.room = nil
+	for i := range r.players {
+		if r.players[i] == c {
+			r.players = append(r.players[:i], r.players[i+1:]...)
+			break
+		}
+	}
+	log.Printf("client left room %s. now %d players", r.code, len(r.players))
+
+	if len(r.players) < r.minPlayers {
+		r.stopTimer()
+	}
+	if len(r.players) == 0 {
+		delete(h.rooms, r.code)
+		log.Printf("room %s closed\n", r.code)
+		return
+	}
+	r.sendInfo()
+}
+
+// kick sends a player back to the public queue on behalf of the room's host
+func (h *Hub) kick(ctx context.Context, host *client, player int) {
+	r := host.room
+	switch {
+	case r == nil || r.players[0] != host:
+		host.send(parse.ErrorJSON(parse.RoomRefused, "only the host can kick players"))
+		return
+	case player <= 0 || player >= len(r.players):
+		host.send(parse.ErrorJSON(parse.RoomRefused, fmt.Sprintf("no player %d to kick", player)))
+		return
+	}
+
+	c := r.players[player]
+	h.leaveRoom(c)
+	c.send(parse.ErrorJSON(parse.Kicked, "kicked from room "+r.code))
+	h.enqueue(ctx, c, c.queue)
+}
+
+// startRoom starts the game of the room on behalf of its host
+func (h *Hub) startRoom(ctx context.Context, host *client) {
+	r := host.room
+	switch {
+	case r == nil || r.players[0] != host:
+		host.send(parse.ErrorJSON(parse.RoomRefused, "only the host can start the game"))
+		return
+	case len(r.players) < r.minPlayers:
+		host.send(parse.ErrorJSON(parse.RoomRefused,
+			fmt.Sprintf("need at least %d players to start", r.minPlayers)))
+		return
+	}
+	h.startRoomGame(ctx, r)
+}
+
+// startRoomGame closes the room and starts matching its players for a game
+func (h *Hub) startRoomGame(ctx context.Context, r *room) {
+	r.stopTimer()
+	delete(h.rooms, r.code)
+	for _, c := range r.players {
+		c.room = nil
+	}
+	log.Printf("room %s starting game\n", r.code)
+	h.startMatch(ctx, r.players, r.minPlayers, r.queue)
+}
+
+// sendInfo sends the room's join code and player list to each player
+func (r *room) sendInfo() {
+	names := make([]string, len(r.players))
+	for i, c := range r.players {
+		names[i] = c.name
+	}
+	for i, c := range r.players {
+		c.send(parse.RoomJSON(r.code, i, names, r.minPlayers, r.maxPlayers))
+	}
+}
+
+// newRoomCode returns a random join code not used by another room
+func (h *Hub) newRoomCode() string {
+	buf := make([]byte, codeLength)
+	for {
+		rand.Read(buf)
+		for i := range buf {
+			buf[i] = codeChars[int(buf[i])%len(codeChars)]
+		}
+		if _, ok := h.rooms[string(buf)]; !ok {
+			return string(buf)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/he-lium/sokoban/parse"
+)
+
+// newRoomServer starts a server whose public queue never fills, so clients
+// only play together through rooms
+func newRoomServer(t *testing.T) *testServer {
+	cfg := testConfig()
+	cfg.RoomSize = MaxRoomSize
+	cfg.MinPlayers = MaxRoomSize
+	return newTestServer(t, cfg)
+}
+
+// expectRoom waits for c to be sent the room's details, failing unless it has
+// the given number of players and c is player me
+func expectRoom(t *testing.T, c *testClient, players, me int) parse.Room {
+	t.Helper()
+	var r parse.Room
+	c.expect("room").decode(t, &r)
+	if len(r.Players) != players || r.Me != me || !r.Players[0].Host {
+		t.Fatalf("got room %+v, expected %d players with me %d", r, players, me)
+	}
+	return r
+}
+
+// expectGame waits for each client to be sent the start of a game of them all
+func expectGame(t *testing.T, clients ...*testClient) {
+	t.Helper()
+	for _, c := range clients {
+		var init parse.GameInit
+		c.expect("game_init").decode(t, &init)
+		if init.NPlayers != len(clients) {
+			t.Fatalf("started a game of %d players, expected %d", init.NPlayers, len(clients))
+		}
+	}
+}
+
+func TestRoomJoin(t *testing.T) {
+	s := newRoomServer(t)
+	a, b, c := s.dial("/ws"), s.dial("/ws"), s.dial("/ws")
+
+	a.send(`{"type":"create_room","min_players":2,"max_players":3}`)
+	r := expectRoom(t, a, 1, 0)
+	if len(r.Code) != codeLength || r.MinPlayers != 2 || r.MaxPlayers != 3 {
+		t.Fatalf("created room %+v, expected a %d character code for 2 to 3 players", r, codeLength)
+	}
+
+	b.send(`{"type":"join_room","code":"` + r.Code + `"}`)
+	expectRoom(t, a, 2, 0)
+	expectRoom(t, b, 2, 1)
+
+	// codes never contain 0, so no room has this one
+	c.send(`{"type":"join_room","code":"00000"}`)
+	c.expectError(parse.RoomRefused)
+
+	// the room starts once full
+	c.send(`{"type":"join_room","code":"` + r.Code + `"}`)
+	expectGame(t, a, b, c)
+}
+
+func TestRoomRefused(t *testing.T) {
+	s := newRoomServer(t)
+	a := s.dial("/ws")
+	a.expect("queued")
+
+	a.send(`{"type":"create_room","min_players":3,"max_players":2}`)
+	a.expectError(parse.RoomRefused)
+	a.send(`{"type":"create_room","max_players":9}`)
+	a.expectError(parse.RoomRefused)
+	a.send(`{"type":"start"}`)
+	a.expectError(parse.RoomRefused)
+	a.send(`{"type":"kick","player":1}`)
+	a.expectError(parse.RoomRefused)
+}
+
+func TestRoomHost(t *testing.T) {
+	s := newRoomServer(t)
+	a, b, c := s.dial("/ws"), s.dial("/ws"), s.dial("/ws")
+
+	a.send(`{"type":"create_room","min_players":2,"max_players":4}`)
+	r := expectRoom(t, a, 1, 0)
+	a.send(`{"type":"start"}`)
+	a.expectError(parse.RoomRefused)
+
+	b.send(`{"type":"join_room","code":"` + r.Code + `"}`)
+	expectRoom(t, a, 2, 0)
+	expectRoom(t, b, 2, 1)
+	c.send(`{"type":"join_room","code":"` + r.Code + `"}`)
+	expectRoom(t, a, 3, 0)
+	expectRoom(t, b, 3, 1)
+	expectRoom(t, c, 3, 2)
+
+	// only the host may start the game or kick players
+	b.send(`{"type":"start"}`)
+	b.expectError(parse.RoomRefused)
+	b.send(`{"type":"kick","player":2}`)
+	b.expectError(parse.RoomRefused)
+
+	a.send(`{"type":"kick","player":5}`)
+	a.expectError(parse.RoomRefused)
+	a.send(`{"type":"kick","player":2}`)
+	c.expectError(parse.Kicked)
+	c.expect("queued")
+	expectRoom(t, a, 2, 0)
+	expectRoom(t, b, 2, 1)
+
+	a.send(`{"type":"start"}`)
+	expectGame(t, a, b)
+	c.expectNone("game_init", 100*time.Millisecond)
+}
+
+func TestRoomNames(t *testing.T) {
+	s := newRoomServer(t)
+	ann, _ := joinAs(s, "ann")
+	b := s.dial("/ws")
+
+	ann.send(`{"type":"create_room"}`)
+	r := expectRoom(t, ann, 1, 0)
+	b.send(`{"type":"join_room","code":"` + r.Code + `"}`)
+	for me, c := range []*testClient{ann, b} {
+		if r := expectRoom(t, c, 2, me); r.Players[0].Name != "ann" || r.Players[1].Name != "" {
+			t.Errorf("got room players %+v, expected ann and a player without a nickname", r.Players)
+		}
+	}
+
+	// the room is sent again once a player joins with a nickname
+	b.send(`{"type":"join","name":"bob"}`)
+	for me, c := range []*testClient{ann, b} {
+		if r := expectRoom(t, c, 2, me); r.Players[0].Name != "ann" || r.Players[1].Name != "bob" {
+			t.Errorf("got room players %+v, expected ann and bob", r.Players)
+		}
+	}
+}
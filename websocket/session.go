@@ -0,0 +1,105 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+)
+
+// Each player of a game is given a session token in the gameInit message.
+// If their connection drops, the Controller holds their slot for the
+// reconnect grace period, and a client connecting to /ws?token=<token> takes
+// over the slot and receives the state of the game
+
+// session identifies a player of a game in progress
+type session struct {
+	controller *Controller
+	player     int
+}
+
+// newToken returns a random session token
+func newToken() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// reconnect attaches c to the game of the session with the given token,
+// taking over the slot of a player dropped by nickname if byName is set.
+// Returns false if there is no such game in progress, or its Controller
+// refused the client
+func (h *Hub) reconnect(token string, c *client, byName bool) bool {
+	h.gameLock.Lock()
+	s, ok := h.sessions[token]
+	h.gameLock.Unlock()
+	if !ok {
+		log.Printf("hub: reconnect with unknown token")
+		return false
+	}
+
+	// the client's goroutines may already be running, and see the game once
+	// playing finds its controller
+	c.playLock.Lock()
+	defer c.playLock.Unlock()
+	reply := make(chan bool, 1)
+	if !s.controller.control(receiveInfo{s.player, reconnect{byName, reply}, c, nil}) {
+		return false
+	}
+	// answered by the Controller, or by receiveDisconnects once it finishes
+	if !<-reply {
+		return false
+	}
+	c.controller = s.controller
+	c.playerID = s.player
+	return true
+}
+
+// resume attaches c to the game of a dropped player with its nickname, taking
+// it out of the public queue, its match or its room. Returns false if no game
+// in progress has a dropped player with the nickname
+func (h *Hub) resume(ctx context.Context, c *client) bool {
+	h.gameLock.Lock()
+	var tokens []string
+	for t, s := range h.sessions {
+		if s.controller.names[s.player] == c.name {
+			tokens = append(tokens, t)
+		}
+	}
+	h.gameLock.Unlock()
+
+	for _, token := range tokens {
+		if !h.reconnect(token, c, true) {
+			continue
+		}
+		// the hub sends nothing more to c, which its Controller now owns
+		if c.room != nil {
+			h.leaveRoom(c)
+		} else if c.match != nil {
+			h.leaveMatch(ctx, c)
+		} else if c.waiting() {
+			h.removeWaiting(ctx, c)
+		}
+		log.Printf("hub: %s rejoined their game", c.name)
+		return true
+	}
+	return false
+}
+
+// forgetSession forgets the session token of a player who has left their
+// game for good
+func (h *Hub) forgetSession(token string) {
+	h.gameLock.Lock()
+	defer h.gameLock.Unlock()
+	delete(h.sessions, token)
+}
+
+// endSessions forgets the session tokens of a finished game
+func (h *Hub) endSessions(c *Controller) {
+	h.gameLock.Lock()
+	defer h.gameLock.Unlock()
+	delete(h.running, c.id)
+	for _, token := range c.tokens {
+		delete(h.sessions, token)
+	}
+}
@@ -0,0 +1,119 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/he-lium/sokoban/parse"
+)
+
+func newSessionServer(t *testing.T) *testServer {
+	cfg := testConfig()
+	cfg.ReconnectGrace = 300 * time.Millisecond
+	return newTestServer(t, cfg)
+}
+
+func TestReconnect(t *testing.T) {
+	s := newSessionServer(t)
+	clients, inits := s.startGame()
+	clients[0].send(`{"type":"move","direction":"up"}`)
+	clients[0].expect("action_result")
+	clients[0].conn.Close()
+
+	c := s.dial("/ws?token=" + inits[0].Token)
+	var resync parse.Resync
+	c.expect("resync").decode(t, &resync)
+	if resync.Me != 0 || !reflect.DeepEqual(resync.History, [][]string{{"up"}, {}}) {
+		t.Errorf("got resync %+v, expected player 0 having moved up", resync)
+	}
+
+	// the reconnected client plays on as player 0
+	c.send(`{"type":"move","direction":"left"}`)
+	if m := c.expect("action_result"); m.Player != 0 {
+		t.Errorf("got result %s, expected one for player 0", m.raw)
+	}
+	if m := clients[1].expect("opponent_action"); m.Player != 0 {
+		t.Errorf("got opponent action %s, expected one of player 0", m.raw)
+	}
+}
+
+func TestReconnectAfterGrace(t *testing.T) {
+	s := newSessionServer(t)
+	clients, inits := s.startGame()
+	clients[0].conn.Close()
+	closed := time.Now()
+
+	eventually(t, "player 0 to leave", func() bool {
+		games := s.hub.inspectGames()
+		return len(games) == 1 && games[0].Players[0].Left
+	})
+	if held := time.Since(closed); held < s.hub.cfg.ReconnectGrace*9/10 {
+		t.Errorf("slot held for %v, expected the grace period of %v", held, s.hub.cfg.ReconnectGrace)
+	}
+	c := s.dial("/ws?token=" + inits[0].Token)
+	c.expectError(parse.ReconnectFailed)
+	c.expect("queued")
+}
+
+// joinAs connects a client which joins with the nickname, returning it with
+// the token issued for the nickname
+func joinAs(s *testServer, name string) (*testClient, string) {
+	s.t.Helper()
+	c := s.dial("/ws")
+	c.send(`{"type":"join","name":"` + name + `"}`)
+	var joined parse.Joined
+	c.expect("joined").decode(s.t, &joined)
+	if joined.Name != name || joined.Token == "" {
+		s.t.Fatalf("joined as %+v, expected %s with a token", joined, name)
+	}
+	return c, joined.Token
+}
+
+// released waits for the nickname to be free for another client to use
+func released(s *testServer, name string) {
+	s.t.Helper()
+	ids := s.hub.identities
+	eventually(s.t, name+" to be released", func() bool {
+		ids.lock.Lock()
+		defer ids.lock.Unlock()
+		return ids.online[name] == nil
+	})
+}
+
+func TestResumeByName(t *testing.T) {
+	s := newRoomServer(t)
+	ann, annToken := joinAs(s, "ann")
+	bob, bobToken := joinAs(s, "bob")
+	cat, _ := joinAs(s, "cat")
+	ann.send(`{"type":"create_room","min_players":3,"max_players":3}`)
+	r := expectRoom(t, ann, 1, 0)
+	bob.send(`{"type":"join_room","code":"` + r.Code + `"}`)
+	expectRoom(t, bob, 2, 1)
+	cat.send(`{"type":"join_room","code":"` + r.Code + `"}`)
+	expectGame(t, ann, bob, cat)
+
+	// a player who finished joins the queue rather than their old game
+	for _, d := range solution3 {
+		ann.send(`{"type":"move","direction":"` + d + `"}`)
+		ann.expect("action_result")
+	}
+	ann.expect("win")
+	ann.conn.Close()
+	released(s, "ann")
+	c := s.dial("/ws")
+	c.send(`{"type":"join","name":"ann","token":"` + annToken + `"}`)
+	c.expect("joined")
+	c.expect("queued")
+	c.expectNone("resync", 100*time.Millisecond)
+
+	// a dropped player takes over their slot
+	bob.conn.Close()
+	released(s, "bob")
+	c = s.dial("/ws")
+	c.send(`{"type":"join","name":"bob","token":"` + bobToken + `"}`)
+	c.expect("joined")
+	if m := c.expect("resync"); m.Me != 1 {
+		t.Errorf("got resync %s, expected it for player 1", m.raw)
+	}
+}
@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/he-lium/sokoban/parse"
+)
+
+// Spectators connect to /ws/spectate?game=<id> to watch a game in progress.
+// They receive the starting board and the moves made so far by each player,
+// followed by every player's actions, wins and the final result. Messages
+// from spectators are ignored
+
+// addSpectator sends the state of the game to a new spectator, and the
+// following actions until the game ends
+func (c *Controller) addSpectator(s *client) {
+	if c.start == nil {
+		// game has not yet been initialised
+		close(s.sendMsg)
+		return
+	}
+	c.spectators[s] = true
+	s.sendMsg <- parse.SpectateJSON(c.start, c.currentBoards())
+	log.Printf("game %p controller: spectator joined. now %d", c, len(c.spectators))
+}
+
+// removeSpectator stops sending the game to a disconnected spectator
+func (c *Controller) removeSpectator(s *client) {
+	if c.spectators[s] {
+		delete(c.spectators, s)
+		close(s.sendMsg)
+		log.Printf("game %p controller: spectator left. now %d", c, len(c.spectators))
+	}
+}
+
+// sendSpectators sends msg to every spectator, disconnecting unresponsive ones
+func (c *Controller) sendSpectators(msg []byte) {
+	for s := range c.spectators {
+		select {
+		case s.sendMsg <- msg:
+		default:
+			log.Printf("controller %p: spectator unresponsive", c)
+			c.removeSpectator(s)
+		}
+	}
+}
+
+// spectate attaches c to the game with the given id as a spectator.
+// Returns false if there is no such game in progress
+func (h *Hub) spectate(id int, c *client) bool {
+	h.gameLock.Lock()
+	ctrl, ok := h.running[id]
+	h.gameLock.Unlock()
+	if !ok {
+		return false
+	}
+	c.controller = ctrl
+	return ctrl.control(receiveInfo{-1, spectate{}, c, nil})
+}
+
+// serveSpectator connects a websocket client which watches a game
+func serveSpectator(hub *Hub, w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	c := &client{
+		sendMsg:  make(chan []byte, 5),
+		conn:     conn,
+		hub:      hub,
+		playerID: -1,
+	}
+	c.sendMsg <- parse.HelloJSON()
+	go c.outgoing()
+
+	id, err := strconv.Atoi(r.URL.Query().Get("game"))
+	if err != nil || !hub.spectate(id, c) {
+		c.sendMsg <- parse.ErrorJSON(parse.NoSuchGame, "no game in progress with that id")
+		close(c.sendMsg)
+		return
+	}
+	hub.metrics.connected.Inc()
+	go c.watchGame()
+}
+
+// watchGame discards messages from a spectator until it disconnects
+func (c *client) watchGame() {
+	defer func() {
+		c.controller.control(receiveInfo{-1, unspectate{}, c, nil})
+		c.hub.metrics.connected.Dec()
+		c.conn.Close()
+	}()
+	c.conn.SetReadLimit(maxMsgSize)
+	c.conn.SetReadDeadline(time.Now().Add(c.hub.cfg.PongWait))
+	c.conn.SetPongHandler(func(appData string) error {
+		c.hub.metrics.pong(appData)
+		c.conn.SetReadDeadline(time.Now().Add(c.hub.cfg.PongWait))
+		return nil
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
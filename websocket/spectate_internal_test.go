@@ -0,0 +1,72 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/he-lium/sokoban/parse"
+)
+
+func TestSpectate(t *testing.T) {
+	s := newTestServer(t, testConfig())
+	clients, _ := s.startGame()
+	clients[0].send(`{"type":"move","direction":"up"}`)
+	clients[0].expect("action_result")
+	clients[1].expect("opponent_action")
+
+	// a spectator is sent the board of every player so far
+	w := s.dial("/ws/spectate?game=0")
+	var state parse.Resync
+	w.expect("spectate").decode(t, &state)
+	if state.NPlayers != 2 || state.Me != -1 ||
+		!reflect.DeepEqual(state.History, [][]string{{"up"}, {}}) {
+		t.Fatalf("got spectate %+v, expected two players with player 0 having moved up", state)
+	}
+
+	// its moves are ignored
+	w.send(`{"type":"move","direction":"left"}`)
+	for _, c := range clients {
+		c.expectNone("opponent_action", 100*time.Millisecond)
+	}
+	games := s.hub.inspectGames()
+	if len(games) != 1 || games[0].Spectators != 1 || len(games[0].Players) != 2 {
+		t.Fatalf("inspected %+v, expected one game of two players with a spectator", games)
+	}
+
+	// it is sent every player's actions and wins, and the game lasts until
+	// both players finish
+	for p, c := range clients {
+		moves := solution3
+		if p == 0 {
+			moves = solution3[1:] // already moved up
+		}
+		for _, d := range moves {
+			c.send(`{"type":"move","direction":"` + d + `"}`)
+			c.expect("action_result")
+			var a parse.OpponentAction
+			w.expect("opponent_action").decode(t, &a)
+			if a.Player != p || a.Direction != d {
+				t.Errorf("spectator got %+v, expected player %d moving %s", a, p, d)
+			}
+		}
+		if m := w.expect("win"); m.Player != p {
+			t.Errorf("spectator got %s, expected player %d to win", m.raw, p)
+		}
+	}
+	var result parse.GameResult
+	w.expect("game_over").decode(t, &result)
+	if !reflect.DeepEqual(result.Order, []int{0, 1}) {
+		t.Errorf("spectator got result %+v, expected players 0 then 1 to finish", result)
+	}
+	w.expectClosed()
+}
+
+func TestSpectateNoSuchGame(t *testing.T) {
+	s := newTestServer(t, testConfig())
+	for _, path := range []string{"/ws/spectate", "/ws/spectate?game=one", "/ws/spectate?game=0"} {
+		w := s.dial(path)
+		w.expectError(parse.NoSuchGame)
+		w.expectClosed()
+	}
+}
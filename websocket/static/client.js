@@ -0,0 +1,480 @@
+// Browser client for the sokoban websocket server. The server sends the
+// starting board once, then only the results of actions, so each board is
+// replayed locally from the actions of its player.
+(function () {
+  "use strict";
+
+  var CELL = 32;          // pixel size of a cell on the player's board
+  var OPPONENT_CELL = 16; // pixel size of a cell on opponents' boards
+  var PROTOCOL_VERSION = 1;
+
+  var EMOTES = {
+    wave: "\uD83D\uDC4B",
+    gg: "GG",
+    laugh: "\uD83D\uDE02",
+    wow: "\uD83D\uDE2E",
+    thumbs_up: "\uD83D\uDC4D",
+    sad: "\uD83D\uDE22"
+  };
+
+  var DELTAS = {
+    up: [0, -1],
+    right: [1, 0],
+    down: [0, 1],
+    left: [-1, 0]
+  };
+
+  var KEYS = {
+    ArrowUp: "up", w: "up",
+    ArrowRight: "right", d: "right",
+    ArrowDown: "down", s: "down",
+    ArrowLeft: "left", a: "left"
+  };
+
+  function key(p) {
+    return p[0] + "," + p[1];
+  }
+
+  // Board replays a player's actions on a copy of the starting board
+  function Board(proto) {
+    var self = this;
+    this.width = proto.width;
+    this.height = proto.height;
+    this.player = proto.player.slice();
+    this.walls = {};
+    this.targets = {};
+    this.boxes = {};
+    this.history = [];
+    this.won = false;
+    proto.walls.forEach(function (p) { self.walls[key(p)] = true; });
+    proto.targets.forEach(function (p) { self.targets[key(p)] = true; });
+    proto.boxes.forEach(function (p) { self.boxes[key(p)] = true; });
+  }
+
+  Board.prototype.open = function (p) {
+    return p[0] >= 0 && p[0] < this.width && p[1] >= 0 && p[1] < this.height &&
+      !this.walls[key(p)];
+  };
+
+  Board.prototype.move = function (dir) {
+    var d = DELTAS[dir];
+    if (!d) {
+      return false;
+    }
+    var next = [this.player[0] + d[0], this.player[1] + d[1]];
+    if (!this.open(next)) {
+      return false;
+    }
+    var pushed = null;
+    if (this.boxes[key(next)]) {
+      var next2 = [next[0] + d[0], next[1] + d[1]];
+      if (!this.open(next2) || this.boxes[key(next2)]) {
+        return false;
+      }
+      delete this.boxes[key(next)];
+      this.boxes[key(next2)] = true;
+      pushed = [next, next2];
+    }
+    this.history.push({ from: this.player, pushed: pushed });
+    this.player = next;
+    return true;
+  };
+
+  Board.prototype.undo = function () {
+    var last = this.history.pop();
+    if (!last) {
+      return false;
+    }
+    this.player = last.from;
+    if (last.pushed) {
+      delete this.boxes[key(last.pushed[1])];
+      this.boxes[key(last.pushed[0])] = true;
+    }
+    return true;
+  };
+
+  Board.prototype.reset = function () {
+    while (this.undo()) {
+    }
+  };
+
+  Board.prototype.apply = function (action, dir) {
+    switch (action) {
+    case "move":
+      return this.move(dir);
+    case "undo":
+      return this.undo();
+    case "reset":
+      this.reset();
+      return true;
+    }
+    return false;
+  };
+
+  Board.prototype.draw = function (canvas, cell) {
+    canvas.width = this.width * cell;
+    canvas.height = this.height * cell;
+    var ctx = canvas.getContext("2d");
+    for (var x = 0; x < this.width; x++) {
+      for (var y = 0; y < this.height; y++) {
+        var k = key([x, y]);
+        ctx.fillStyle = this.walls[k] ? "#555" : "#ddd";
+        ctx.fillRect(x * cell, y * cell, cell, cell);
+        if (this.targets[k]) {
+          ctx.fillStyle = "#c33";
+          ctx.fillRect(x * cell + cell / 3, y * cell + cell / 3, cell / 3, cell / 3);
+        }
+        if (this.boxes[k]) {
+          ctx.fillStyle = this.targets[k] ? "#3a3" : "#a63";
+          ctx.fillRect(x * cell + 2, y * cell + 2, cell - 4, cell - 4);
+        }
+      }
+    }
+    ctx.fillStyle = "#36c";
+    ctx.beginPath();
+    ctx.arc((this.player[0] + 0.5) * cell, (this.player[1] + 0.5) * cell,
+      cell / 3, 0, 2 * Math.PI);
+    ctx.fill();
+  };
+
+  var me = -1;
+  var spectating = new URLSearchParams(location.search).get("spectate");
+  var token = "";      // session token for reconnecting to the game
+  var names = [];      // nickname of each player, empty if not joined
+  var over = false;    // whether the game has finished
+  var conn = null;
+  var boards = [];
+  var pending = []; // own actions awaiting a result from the server
+  var lastSeq = 0;  // sequence number of the last message received
+  var canvases = [];
+  var captions = [];
+
+  function status(text) {
+    document.getElementById("status").textContent = text;
+  }
+
+  function log(text) {
+    var item = document.createElement("li");
+    item.textContent = text;
+    document.getElementById("log").appendChild(item);
+  }
+
+  function name(p) {
+    return p === me ? "You" : names[p] || "Player " + (p + 1);
+  }
+
+  // sender names a chat sender, who may be waiting for a game to start
+  function sender(p) {
+    if (boards.length > 0) {
+      return name(p);
+    }
+    return p < 0 ? "Someone" : "Player " + (p + 1);
+  }
+
+  function draw(p) {
+    boards[p].draw(canvases[p], p === me || spectating ? CELL : OPPONENT_CELL);
+    if (captions[p]) {
+      captions[p].textContent = name(p) + ": " + boards[p].history.length +
+        " moves" + (boards[p].won ? " (finished)" : "");
+    }
+  }
+
+  function init(msg) {
+    me = msg.me;
+    boards = [];
+    canvases = [];
+    captions = [];
+    pending = [];
+    var opponents = document.getElementById("opponents");
+    opponents.innerHTML = "";
+    document.getElementById("mine").hidden = me < 0;
+    for (var p = 0; p < msg.num_players; p++) {
+      boards.push(new Board(msg.board));
+      if (p === me) {
+        canvases.push(document.getElementById("board"));
+        captions.push(null);
+      } else {
+        var div = document.createElement("div");
+        div.className = "opponent";
+        var caption = document.createElement("p");
+        var canvas = document.createElement("canvas");
+        div.appendChild(caption);
+        div.appendChild(canvas);
+        opponents.appendChild(div);
+        canvases.push(canvas);
+        captions.push(caption);
+      }
+      draw(p);
+    }
+    status("Game started with " + msg.num_players + " players");
+  }
+
+  // resync rebuilds every board from the history sent after reconnecting
+  function resync(msg) {
+    init(msg);
+    msg.history.forEach(function (moves, p) {
+      moves.forEach(function (dir) {
+        boards[p].move(dir);
+      });
+      boards[p].won = msg.won[p];
+      draw(p);
+    });
+  }
+
+  function handle(msg) {
+    if (msg.seq !== undefined) {
+      if (lastSeq > 0 && msg.seq !== lastSeq + 1 && me >= 0 && !over) {
+        // missed a message: ask for the full state of the game
+        conn.send(JSON.stringify({ type: "state" }));
+      }
+      lastSeq = msg.seq;
+    }
+    switch (msg.type) {
+    case "hello":
+      if (msg.version !== PROTOCOL_VERSION) {
+        log("Server speaks protocol version " + msg.version);
+      }
+      break;
+    case "match":
+      var nReady = msg.ready.filter(function (r) { return r; }).length;
+      document.getElementById("ready").hidden = msg.ready[msg.me];
+      status("Matched with " + (msg.ready.length - 1) + " other player(s). " +
+        nReady + " of " + msg.ready.length + " ready, " +
+        Math.ceil(msg.timeout_ms / 1000) + "s left to be ready");
+      break;
+    case "countdown":
+      status("Starting in " + msg.seconds + "...");
+      break;
+    case "joined":
+      if (msg.token) {
+        // keep the token to join with the nickname again
+        localStorage.setItem("sokoban-token:" + msg.name, msg.token);
+      }
+      log("Joined as " + msg.name);
+      break;
+    case "queued":
+      status("Waiting in queue " + msg.queue + " at rating " + msg.rating + "...");
+      break;
+    case "requeued":
+      document.getElementById("ready").hidden = true;
+      status("Waiting for other players... (" + msg.reason + ")");
+      break;
+    case "game_init":
+      document.getElementById("ready").hidden = true;
+      token = msg.token;
+      names = msg.names;
+      init(msg);
+      break;
+    case "state":
+      // actions the server hadn't processed yet are still awaiting results
+      var waiting = pending;
+      resync(msg);
+      pending = waiting;
+      break;
+    case "resync":
+      resync(msg);
+      log("Reconnected to game");
+      break;
+    case "spectate":
+      resync(msg);
+      status("Spectating game " + spectating);
+      break;
+    case "action_result":
+      // result of own action
+      var a = pending.shift();
+      if (a && msg.move_valid) {
+        boards[me].apply(a.type, a.direction);
+        draw(me);
+      }
+      break;
+    case "opponent_action":
+      names[msg.player] = msg.name || names[msg.player];
+      boards[msg.player].apply(msg.action, msg.direction);
+      draw(msg.player);
+      break;
+    case "win":
+      names[msg.player] = msg.name || names[msg.player];
+      boards[msg.player].won = true;
+      draw(msg.player);
+      log(name(msg.player) + " finished!");
+      if (msg.player === me) {
+        status("You win!");
+      }
+      break;
+    case "personal_best":
+      log("New personal best! (" + msg.records.map(function (r) {
+        return { time: "fastest time", moves: "fewest moves", pushes: "fewest pushes" }[r];
+      }).join(", ") + ")");
+      break;
+    case "turn":
+      status(msg.player === me ? "Your turn" : name(msg.player) + "'s turn");
+      break;
+    case "limits":
+      var parts = [];
+      if (msg.time_left_ms >= 0) {
+        parts.push(Math.ceil(msg.time_left_ms / 1000) + "s left");
+      }
+      if (msg.moves_left >= 0) {
+        parts.push(msg.moves_left + " moves left");
+      }
+      if (msg.pushes_left >= 0) {
+        parts.push(msg.pushes_left + " pushes left");
+      }
+      status(parts.join(", "));
+      break;
+    case "game_over":
+      var ranks = msg.order.map(function (p, i) {
+        return (i + 1) + ". " + name(p);
+      });
+      over = true;
+      status("Game over. " + (ranks.length ? ranks.join("  ") : "Nobody finished"));
+      break;
+    case "chat":
+      log(sender(msg.player) + ": " + msg.text);
+      break;
+    case "emote":
+      log(sender(msg.player) + " " + (EMOTES[msg.emote] || msg.emote));
+      break;
+    case "notice":
+      log("Server notice: " + msg.text);
+      break;
+    case "error":
+      if (msg.code === "reconnect_failed" || msg.code === "kicked") {
+        token = "";
+      }
+      log(msg.message);
+      break;
+    }
+  }
+
+  function connect() {
+    var url = (location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/ws";
+    if (spectating) {
+      url += "/spectate?game=" + encodeURIComponent(spectating);
+    } else if (token) {
+      url += "?token=" + encodeURIComponent(token);
+    }
+    conn = new WebSocket(url);
+    lastSeq = 0;
+
+    conn.onopen = function () {
+      conn.send(JSON.stringify({ type: "hello", version: PROTOCOL_VERSION }));
+      if (!token && !spectating) {
+        status("Waiting for other players...");
+      }
+    };
+
+    conn.onclose = function () {
+      log("Disconnected from server");
+      if (token && !over) {
+        // try to rejoin the game in progress
+        setTimeout(connect, 1000);
+      }
+    };
+
+    conn.onmessage = function (evt) {
+      // the server may send several JSON objects per message, one per line
+      evt.data.split("\n").forEach(function (line) {
+        if (line.trim() !== "") {
+          handle(JSON.parse(line));
+        }
+      });
+    };
+  }
+
+  function send(type, direction) {
+    if (me < 0 || boards[me].won || conn.readyState !== WebSocket.OPEN) {
+      return;
+    }
+    var msg = { type: type };
+    if (direction) {
+      msg.direction = direction;
+    }
+    pending.push(msg);
+    conn.send(JSON.stringify(msg));
+  }
+
+  // chat sends a chat message or emote, which works before the game starts
+  function chat(msg) {
+    if (!spectating && conn.readyState === WebSocket.OPEN) {
+      conn.send(JSON.stringify(msg));
+    }
+  }
+
+  document.getElementById("ready").addEventListener("click", function () {
+    conn.send(JSON.stringify({ type: "ready" }));
+    this.hidden = true;
+  });
+
+  document.getElementById("queue").addEventListener("submit", function (evt) {
+    evt.preventDefault();
+    if (me < 0 && conn.readyState === WebSocket.OPEN) {
+      conn.send(JSON.stringify({
+        type: "join_queue",
+        queue: document.getElementById("queue-name").value
+      }));
+    }
+  });
+
+  document.getElementById("join").addEventListener("submit", function (evt) {
+    var nickname = document.getElementById("nickname").value.trim();
+    evt.preventDefault();
+    if (me < 0 && nickname !== "" && conn.readyState === WebSocket.OPEN) {
+      conn.send(JSON.stringify({
+        type: "join",
+        name: nickname,
+        token: localStorage.getItem("sokoban-token:" + nickname) || undefined
+      }));
+    }
+  });
+
+  document.getElementById("ghost").addEventListener("submit", function (evt) {
+    evt.preventDefault();
+    if (me < 0 && conn.readyState === WebSocket.OPEN) {
+      var replay = document.getElementById("ghost-replay").value;
+      conn.send(JSON.stringify({
+        type: "race_ghost",
+        replay: replay,
+        solver: replay === ""
+      }));
+    }
+  });
+
+  document.getElementById("chat").addEventListener("submit", function (evt) {
+    var input = document.getElementById("chat-text");
+    evt.preventDefault();
+    if (input.value.trim() !== "") {
+      chat({ type: "chat", text: input.value });
+    }
+    input.value = "";
+  });
+
+  Object.keys(EMOTES).forEach(function (emote) {
+    var button = document.createElement("button");
+    button.type = "button";
+    button.textContent = EMOTES[emote];
+    button.addEventListener("click", function () {
+      chat({ type: "emote", emote: emote });
+    });
+    document.getElementById("emotes").appendChild(button);
+  });
+
+  connect();
+
+  document.addEventListener("keydown", function (evt) {
+    if (evt.target.tagName === "INPUT") {
+      return; // typing a chat message
+    }
+    var dir = KEYS[evt.key];
+    if (dir) {
+      send("move", dir);
+    } else if (evt.key === "u") {
+      send("undo");
+    } else if (evt.key === "r") {
+      send("reset");
+    } else {
+      return;
+    }
+    evt.preventDefault();
+  });
+}());